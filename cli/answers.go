@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Section holding the cloud selection and the values returned by a
+	// cloud provider's GetProviderVars (credentials, project, region...)
+	ProviderSection = "provider"
+)
+
+// Answers holds pre-supplied responses for a non-interactive run.
+// Values are keyed by section and variable name. A section is either
+// ProviderSection or a module path ("root", "control-nodes", ...)
+//
+//	provider:
+//	  cloud: google
+//	  project: my-project
+//	  region: us-central1
+//	  zones: [us-central1-a, us-central1-b]
+//	root:
+//	  short_name: mantl
+//	worker-nodes:
+//	  count: 5
+type Answers struct {
+	values    map[string]map[string]interface{}
+	missing   map[string]bool
+	sensitive map[string]bool
+}
+
+func NewAnswers() *Answers {
+	return &Answers{
+		values:    make(map[string]map[string]interface{}),
+		missing:   make(map[string]bool),
+		sensitive: make(map[string]bool),
+	}
+}
+
+// ReadAnswers loads an answers file. YAML is a superset of JSON so
+// both formats are accepted.
+func ReadAnswers(path string) (*Answers, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Error parsing answers file %s: %s", path, err)
+	}
+
+	a := NewAnswers()
+	for section, v := range raw {
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid answers section '%s': expected a map", section)
+		}
+
+		for name, value := range m {
+			a.Set(section, fmt.Sprint(name), normalize(value))
+		}
+	}
+
+	return a, nil
+}
+
+// AnswersFromValues returns the answers in values, as returned by Values
+func AnswersFromValues(values map[string]map[string]interface{}) *Answers {
+	a := NewAnswers()
+	for section, names := range values {
+		for name, value := range names {
+			a.Set(section, name, value)
+		}
+	}
+
+	return a
+}
+
+// WriteAnswers saves the answers in a format ReadAnswers can replay.
+// Sensitive values are left out; a replay reports them as missing.
+func (a *Answers) WriteAnswers(path string) error {
+	data, err := yaml.Marshal(a.Values(false))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Values returns either the sensitive or the other values by section
+func (a *Answers) Values(sensitive bool) map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+	for section, names := range a.values {
+		for name, value := range names {
+			if a.sensitive[answerKey(section, name)] != sensitive {
+				continue
+			}
+
+			if _, ok := out[section]; !ok {
+				out[section] = make(map[string]interface{})
+			}
+			out[section][name] = value
+		}
+	}
+
+	return out
+}
+
+func (a *Answers) Get(section, name string) (interface{}, bool) {
+	s, ok := a.values[section]
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := s[name]
+	return v, ok
+}
+
+// GetString returns an answer converted to a string. Lists are
+// joined with commas to match the string encoding used by the
+// builtin modules.
+func (a *Answers) GetString(section, name string) (string, bool) {
+	v, ok := a.Get(section, name)
+	if !ok {
+		return "", false
+	}
+
+	return stringValue(v), true
+}
+
+func (a *Answers) Set(section, name string, value interface{}) {
+	if _, ok := a.values[section]; !ok {
+		a.values[section] = make(map[string]interface{})
+	}
+
+	a.values[section][name] = value
+}
+
+// Sensitive marks a value that must never be written by WriteAnswers
+func (a *Answers) Sensitive(section, name string) {
+	a.sensitive[answerKey(section, name)] = true
+}
+
+// Missing records a required value that was not in the answers file
+func (a *Answers) Missing(section, name string) {
+	a.missing[answerKey(section, name)] = true
+}
+
+// Check returns a MissingError listing every key recorded by Missing
+func (a *Answers) Check() error {
+	if len(a.missing) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(a.missing))
+	for k := range a.missing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return &MissingError{Keys: keys}
+}
+
+type MissingError struct {
+	Keys []string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("Missing required answers: %s", strings.Join(e.Keys, ", "))
+}
+
+func answerKey(section, name string) string {
+	return section + "." + name
+}
+
+// normalize converts the map[interface{}]interface{} values produced by
+// the YAML decoder to map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, e := range t {
+			l[i] = normalize(e)
+		}
+		return l
+	}
+
+	return v
+}
+
+func stringValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []interface{}:
+		s := make([]string, len(t))
+		for i, e := range t {
+			s[i] = stringValue(e)
+		}
+		return strings.Join(s, ",")
+	}
+
+	return fmt.Sprint(v)
+}
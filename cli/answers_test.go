@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeAnswers(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "pony-answers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "answers.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadAnswers(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeAnswers(t, `{
+		"provider": {"cloud": "google", "zones": ["us-central1-a", "us-central1-b"]},
+		"worker-nodes": {"count": 5}
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	a, err := ReadAnswers(path)
+	assert.Nil(err)
+
+	cases := []struct {
+		Section  string
+		Name     string
+		Expected string
+		Found    bool
+	}{
+		{ProviderSection, "cloud", "google", true},
+		{ProviderSection, "zones", "us-central1-a,us-central1-b", true},
+		{"worker-nodes", "count", "5", true},
+		{"worker-nodes", "machine_type", "", false},
+		{"edge-nodes", "count", "", false},
+	}
+
+	for _, cse := range cases {
+		result, ok := a.GetString(cse.Section, cse.Name)
+		assert.Equal(cse.Found, ok)
+		assert.Equal(cse.Expected, result)
+	}
+}
+
+func TestReadAnswers_invalidSection(t *testing.T) {
+	path := writeAnswers(t, `{"provider": "google"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := ReadAnswers(path)
+	assert.NotNil(t, err)
+}
+
+func TestAnswers_Check(t *testing.T) {
+	assert := assert.New(t)
+
+	a := NewAnswers()
+	assert.Nil(a.Check())
+
+	a.Missing("worker-nodes", "count")
+	a.Missing(ProviderSection, "project")
+	a.Missing("worker-nodes", "count")
+
+	err := a.Check()
+	if assert.NotNil(err) {
+		assert.Equal([]string{"provider.project", "worker-nodes.count"}, err.(*MissingError).Keys)
+	}
+}
+
+func TestCli_nonInteractive(t *testing.T) {
+	assert := assert.New(t)
+
+	c := New(new(nopReader), ioutil.Discard)
+	c.SetAnswers(NewAnswers())
+
+	_, err := c.AskRequired("Test case")
+	assert.NotNil(err)
+
+	result, err := c.AskRequiredWithDefault("Test case", "default")
+	assert.Nil(err)
+	assert.Equal("default", result)
+
+	assert.True(c.AskYesNo("Test case", "y"))
+	assert.False(c.AskYesNo("Test case", "n"))
+
+	_, err = c.Select("test", []string{"Chris", "Jenny"})
+	assert.NotNil(err)
+}
+
+// nopReader fails the test run if anything tries to read input
+type nopReader struct{}
+
+func (r *nopReader) Read(p []byte) (int, error) {
+	panic("read from input in non-interactive mode")
+}
+
+func TestAnswers_WriteAnswers(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "pony-answers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "answers.yml")
+
+	a := NewAnswers()
+	a.Set(ProviderSection, "cloud", "google")
+	a.Set("root", "short_name", "mantl")
+	a.Set("root", "password", "secret")
+	a.Sensitive("root", "password")
+
+	assert.Nil(a.WriteAnswers(path))
+
+	replay, err := ReadAnswers(path)
+	assert.Nil(err)
+
+	result, ok := replay.GetString(ProviderSection, "cloud")
+	assert.True(ok)
+	assert.Equal("google", result)
+
+	result, ok = replay.GetString("root", "short_name")
+	assert.True(ok)
+	assert.Equal("mantl", result)
+
+	_, ok = replay.GetString("root", "password")
+	assert.False(ok)
+}
+
+func TestAnswers_Values(t *testing.T) {
+	assert := assert.New(t)
+
+	a := NewAnswers()
+	a.Set("root", "short_name", "mantl")
+	a.Set("root", "password", "secret")
+	a.Sensitive("root", "password")
+
+	assert.Equal(map[string]map[string]interface{}{
+		"root": {"short_name": "mantl"},
+	}, a.Values(false))
+	assert.Equal(map[string]map[string]interface{}{
+		"root": {"password": "secret"},
+	}, a.Values(true))
+
+	replay := AnswersFromValues(a.Values(false))
+	result, ok := replay.GetString("root", "short_name")
+	assert.True(ok)
+	assert.Equal("mantl", result)
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"strings"
+)
+
+// Reserved responses that step back to the previous question. They are
+// accepted by every prompt and never returned as a value.
+const (
+	BackInput   = "<"
+	BackCommand = ":back"
+)
+
+type BackError struct{}
+
+func (e *BackError) Error() string { return "Back to the previous question" }
+
+// IsBack reports whether the user asked to go back to the previous
+// question
+func IsBack(err error) bool {
+	switch err.(type) {
+	case *BackError:
+		return true
+	}
+
+	return false
+}
+
+func isBackInput(input string) bool {
+	input = strings.TrimSpace(input)
+	return input == BackInput || input == BackCommand
+}
+
+func checkBack(input string) error {
+	if isBackInput(input) {
+		return new(BackError)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBack(t *testing.T) {
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, input := range []string{"<\n", ":back\n", "  <  \n"} {
+		rsp.Reset()
+		rsp.WriteString(input)
+		_, err := c.AskRequired("Test case")
+		assert.True(IsBack(err), input)
+
+		rsp.Reset()
+		rsp.WriteString(input)
+		_, err = c.AskRequiredWithDefault("Test case", "default")
+		assert.True(IsBack(err), input)
+
+		rsp.Reset()
+		rsp.WriteString(input)
+		_, err = c.Confirm("Test case", "n")
+		assert.True(IsBack(err), input)
+
+		rsp.Reset()
+		rsp.WriteString(input)
+		_, err = c.Select("test", []string{"a", "b"})
+		assert.True(IsBack(err), input)
+	}
+
+	rsp.Reset()
+	rsp.WriteString("a,b\n")
+	_, err := c.AskRequired("Test case")
+	assert.False(IsBack(err))
+}
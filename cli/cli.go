@@ -13,6 +13,9 @@ type Cli struct {
 	w io.Writer
 
 	a interact.Actor
+
+	answers  *Answers
+	recorder *Answers
 }
 
 func New(r io.Reader, w io.Writer) *Cli {
@@ -23,12 +26,90 @@ func New(r io.Reader, w io.Writer) *Cli {
 	}
 }
 
-func (c *Cli) AskRequired(prompt string) (string, error) {
+// SetAnswers switches the Cli to non-interactive mode. Callers look up
+// values with Answer and no prompt will read from the input stream.
+func (c *Cli) SetAnswers(a *Answers) {
+	c.answers = a
+}
+
+func (c *Cli) Interactive() bool {
+	return c.answers == nil
+}
+
+func (c *Cli) Answer(section, name string) (string, bool) {
+	if c.answers == nil {
+		return "", false
+	}
+
+	return c.answers.GetString(section, name)
+}
+
+// AnswerValue returns an answer without converting it to a string.
+// Lists and maps keep their structure.
+func (c *Cli) AnswerValue(section, name string) (interface{}, bool) {
+	if c.answers == nil {
+		return nil, false
+	}
+
+	return c.answers.Get(section, name)
+}
+
+func (c *Cli) MissingAnswer(section, name string) {
+	if c.answers != nil {
+		c.answers.Missing(section, name)
+	}
+}
+
+// CheckAnswers returns an error listing every missing answer. It
+// always succeeds in interactive mode.
+func (c *Cli) CheckAnswers() error {
+	if c.answers == nil {
+		return nil
+	}
+
+	return c.answers.Check()
+}
+
+// SetRecorder keeps a copy of every value passed to Record
+func (c *Cli) SetRecorder(a *Answers) {
+	c.recorder = a
+}
+
+func (c *Cli) Recorder() *Answers {
+	return c.recorder
+}
+
+// RecordSensitive keeps a recorded value out of the answers file
+func (c *Cli) RecordSensitive(section, name string) {
+	if c.recorder != nil {
+		c.recorder.Sensitive(section, name)
+	}
+}
+
+// Record saves a response under the same key Answer reads it from
+func (c *Cli) Record(section, name string, value interface{}) {
+	if c.recorder != nil {
+		c.recorder.Set(section, name, value)
+	}
+}
+
+func (c *Cli) AskRequired(prompt string, checks ...Check) (string, error) {
+	if !c.Interactive() {
+		return "", nonInteractiveError(prompt)
+	}
+
+	inputChecks := append([]interact.InputCheck{checkBack, checkNotEmpty}, toInputChecks(checks)...)
+
 	for {
-		result, err := c.a.Prompt(prompt, checkNotEmpty)
+		result, err := c.a.Prompt(prompt, inputChecks...)
 		switch {
 		case isEmpty(err):
 			continue
+		case IsBack(err):
+			return "", err
+		case isInvalid(err):
+			c.Println(err)
+			continue
 		case err != nil:
 			return "", err
 		}
@@ -37,27 +118,101 @@ func (c *Cli) AskRequired(prompt string) (string, error) {
 	}
 }
 
-func (c *Cli) AskRequiredWithDefault(prompt, def string) (string, error) {
+func (c *Cli) AskRequiredWithDefault(prompt, def string, checks ...Check) (string, error) {
 	// Use AskRequired function if a default is not set
 	if def == "" {
-		return c.AskRequired(prompt)
+		return c.AskRequired(prompt, checks...)
+	}
+
+	if !c.Interactive() {
+		return def, nil
+	}
+
+	inputChecks := append([]interact.InputCheck{checkBack}, toInputChecks(checks)...)
+
+	for {
+		result, err := c.a.PromptOptional(prompt, def, inputChecks...)
+		switch {
+		case IsBack(err):
+			return "", err
+		case isInvalid(err):
+			c.Println(err)
+			continue
+		case err != nil:
+			return "", err
+		}
+
+		return result, nil
+	}
+}
+
+// AskNumber asks for an integer. An empty response keeps the default.
+func (c *Cli) AskNumber(prompt, def string, checks ...Check) (string, error) {
+	if !c.Interactive() {
+		if def == "" {
+			return "", nonInteractiveError(prompt)
+		}
+		return def, nil
+	}
+
+	inputChecks := append([]interact.InputCheck{checkIsNumeric}, toInputChecks(checks)...)
+
+	for {
+		var result string
+		var err error
+
+		if def == "" {
+			result, err = c.a.Prompt(prompt, append([]interact.InputCheck{checkBack, checkNotEmpty}, inputChecks...)...)
+		} else {
+			result, err = c.a.PromptOptional(prompt, def, append([]interact.InputCheck{checkBack}, inputChecks...)...)
+		}
+
+		switch {
+		case isEmpty(err):
+			continue
+		case IsBack(err):
+			return "", err
+		case isNotNumeric(err):
+			c.Println("Value must be a whole number")
+			continue
+		case isInvalid(err):
+			c.Println(err)
+			continue
+		case err != nil:
+			return "", err
+		}
+
+		return result, nil
 	}
+}
 
-	result, err := c.a.PromptOptional(prompt, def)
-	if err != nil {
-		return "", err
+// Confirm is AskYesNo for the configuration wizard. It returns a
+// BackError when the user steps back.
+func (c *Cli) Confirm(prompt, def string) (bool, error) {
+	result := def
+	if c.Interactive() {
+		rsp, err := c.a.PromptOptional(prompt, def, checkBack)
+		if err != nil {
+			return false, err
+		}
+		result = rsp
 	}
 
-	return result, nil
+	m, _ := regexp.MatchString("^[Yy][Ee]?[Ss]?$", result)
+	return m, nil
 }
 
 func (c *Cli) AskYesNo(prompt, def string) bool {
-	result, err := c.a.PromptOptional(prompt, def)
-	if err != nil {
-		return false
+	result := def
+	if c.Interactive() {
+		rsp, err := c.a.PromptOptional(prompt, def)
+		if err != nil {
+			return false
+		}
+		result = rsp
 	}
 
-	m, err := regexp.MatchString("^[Yy][Ee]?[Ss]?$", result)
+	m, _ := regexp.MatchString("^[Yy][Ee]?[Ss]?$", result)
 	return m
 }
 
@@ -68,3 +223,7 @@ func (c *Cli) Printf(format string, a ...interface{}) (int, error) {
 func (c *Cli) Println(a ...interface{}) (int, error) {
 	return fmt.Fprintln(c.w, a...)
 }
+
+func nonInteractiveError(prompt string) error {
+	return fmt.Errorf("Input required in non-interactive mode: %s", prompt)
+}
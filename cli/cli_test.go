@@ -2,7 +2,9 @@ package cli
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -101,3 +103,60 @@ func Test_AskYesNo(t *testing.T) {
 		assert.Equal(cse.Expected, result)
 	}
 }
+
+func Test_AskRequiredWithDefault_checks(t *testing.T) {
+	onlyLower := func(s string) error {
+		if s != strings.ToLower(s) {
+			return fmt.Errorf("'%s' must be lowercase", s)
+		}
+		return nil
+	}
+
+	cases := []struct {
+		Input    string
+		Default  string
+		Expected string
+	}{
+		{"Bad\ngood\n", "", "good"},
+		{"Bad\n\n", "default", "default"},
+		{"ok\n", "default", "ok"},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.AskRequiredWithDefault("Test case", cse.Default, onlyLower)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
+
+func Test_AskSecret(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Default  string
+		Expected string
+	}{
+		{"secret\n", "", "secret"},
+		{"\nsecret\n", "", "secret"},
+		{"\n", "default", "default"},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.AskSecret("Test case", cse.Default)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
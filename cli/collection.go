@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AskList reads list items one per line until an empty line. An empty
+// first line keeps the default.
+func (c *Cli) AskList(varName string, def []string, checks ...Check) ([]string, error) {
+	if !c.Interactive() {
+		if len(def) == 0 {
+			return nil, nonInteractiveError(varName)
+		}
+		return def, nil
+	}
+
+	c.Printf("Enter values for %s, one per line. Enter an empty line to finish\n", varName)
+	if len(def) > 0 {
+		c.Printf("Current value: %s (press enter to keep)\n", strings.Join(def, ", "))
+	}
+
+	rval := []string{}
+	for {
+		rsp, err := c.a.Prompt(fmt.Sprintf("%s[%d]", varName, len(rval)+1), checkBack)
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp != "" {
+			if err := runChecks(rsp, checks); err != nil {
+				c.Println(err)
+				continue
+			}
+			rval = append(rval, rsp)
+			continue
+		}
+
+		switch {
+		case len(rval) > 0:
+			return rval, nil
+		case len(def) > 0:
+			return def, nil
+		}
+	}
+}
+
+// AskMap reads key=value pairs one per line until an empty line. An
+// empty first line keeps the default.
+func (c *Cli) AskMap(varName string, def map[string]string) (map[string]string, error) {
+	if !c.Interactive() {
+		if len(def) == 0 {
+			return nil, nonInteractiveError(varName)
+		}
+		return def, nil
+	}
+
+	c.Printf("Enter key=value pairs for %s, one per line. Enter an empty line to finish\n", varName)
+	if len(def) > 0 {
+		c.Printf("Current value: %s (press enter to keep)\n", formatMap(def))
+	}
+
+	rval := make(map[string]string)
+	for {
+		rsp, err := c.a.Prompt(fmt.Sprintf("%s[%d]", varName, len(rval)+1), checkBack)
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp != "" {
+			k, v, ok := SplitPair(rsp)
+			if !ok {
+				c.Printf("Invalid entry '%s'. Expected key=value\n", rsp)
+				continue
+			}
+			rval[k] = v
+			continue
+		}
+
+		switch {
+		case len(rval) > 0:
+			return rval, nil
+		case len(def) > 0:
+			return def, nil
+		}
+	}
+}
+
+// runChecks is used where an empty line has a meaning of its own and
+// the checks can't be passed to the prompt
+func runChecks(s string, checks []Check) error {
+	for _, check := range checks {
+		if err := check(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SplitPair splits a key=value string. Whitespace around the key and
+// value is removed.
+func SplitPair(s string) (string, string, bool) {
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	key := strings.TrimSpace(s[:idx])
+	if key == "" {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(s[idx+1:]), true
+}
+
+func formatMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + m[k]
+	}
+
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAskList(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Default  []string
+		Expected []string
+	}{
+		{
+			"a\nb\n\n",
+			nil,
+			[]string{"a", "b"},
+		},
+		{
+			"\n",
+			[]string{"a", "b"},
+			[]string{"a", "b"},
+		},
+		{
+			"c\n\n",
+			[]string{"a", "b"},
+			[]string{"c"},
+		},
+		{
+			"\n\na\n\n",
+			nil,
+			[]string{"a"},
+		},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.AskList("test", cse.Default)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
+
+func TestAskMap(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Default  map[string]string
+		Expected map[string]string
+	}{
+		{
+			"a=1\nb = 2\n\n",
+			nil,
+			map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			"novalue\n=1\na=1\n\n",
+			nil,
+			map[string]string{"a": "1"},
+		},
+		{
+			"\n",
+			map[string]string{"a": "1"},
+			map[string]string{"a": "1"},
+		},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.AskMap("test", cse.Default)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
+
+func TestAskNumber(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Default  string
+		Expected string
+	}{
+		{"3\n", "", "3"},
+		{"three\n\n4\n", "", "4"},
+		{"\n", "2", "2"},
+		{"x\n5\n", "2", "5"},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.AskNumber("Test case", cse.Default)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bgentry/speakeasy"
+	"github.com/mattn/go-isatty"
+)
+
+// readSecret reads a line without echo when the input is a terminal.
+// Other readers, such as the buffers used in tests, are read normally.
+func (c *Cli) readSecret(prompt string) (string, error) {
+	if f, ok := c.r.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		return speakeasy.Ask(prompt + ": ")
+	}
+
+	return c.a.Prompt(prompt)
+}
+
+// AskSecret asks for a value without echoing it. An empty response
+// keeps the default, which is never shown.
+func (c *Cli) AskSecret(prompt, def string, checks ...Check) (string, error) {
+	if !c.Interactive() {
+		if def == "" {
+			return "", nonInteractiveError(prompt)
+		}
+		return def, nil
+	}
+
+	if def != "" {
+		prompt = fmt.Sprintf("%s (press enter to keep the current value)", prompt)
+	}
+
+	for {
+		result, err := c.readSecret(prompt)
+		if err != nil {
+			return "", err
+		}
+
+		if err := checkBack(result); err != nil {
+			return "", err
+		}
+
+		if err := checkNotEmpty(result); isEmpty(err) {
+			if def != "" {
+				return def, nil
+			}
+			continue
+		}
+
+		if err := runChecks(result, checks); err != nil {
+			c.Println(err)
+			continue
+		}
+
+		return result, nil
+	}
+}
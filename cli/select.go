@@ -3,8 +3,22 @@ package cli
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
+const (
+	pageSize = 20
+
+	nextPage = "+"
+	prevPage = "-"
+
+	selectAll  = "all"
+	selectNone = "none"
+)
+
+// Select asks for one item of list. The response may be the item's
+// number, its exact name or text that narrows the list to the items
+// containing it. An empty response clears the filter.
 func (c *Cli) Select(varName string, list []string) (string, error) {
 	count := len(list)
 
@@ -13,34 +27,56 @@ func (c *Cli) Select(varName string, list []string) (string, error) {
 	}
 
 	prompt := fmt.Sprintf("Enter value for %s (1-%d)", varName, count)
+	if !c.Interactive() {
+		return "", nonInteractiveError(prompt)
+	}
+
+	view := newListView(list)
 
 	for {
 		c.Println()
-		for i, item := range list {
-			c.Printf("%2d %s\n", i+1, item)
+		view.print(c, nil)
+		c.Println("Enter a number, a name or text to filter the list")
+
+		result, err := c.a.Prompt(prompt, checkBack)
+		if err != nil {
+			return "", err
 		}
 
-		result, err := c.a.Prompt(prompt, checkIsNumeric, checkNotEmpty)
-		switch {
-		case isEmpty(err):
-			fallthrough
-		case isNotNumeric(err):
+		switch result {
+		case "":
+			view.setFilter("")
+			continue
+		case nextPage:
+			view.next()
+			continue
+		case prevPage:
+			view.prev()
 			continue
-		case err != nil:
-			return "", err
 		}
 
-		// String conversion errors are caught by the checkIsNumeric check
-		rval, _ := strconv.Atoi(result)
+		if rval, err := strconv.Atoi(result); err == nil {
+			if (rval < 1) || (rval > count) {
+				continue
+			}
+
+			return list[rval-1], nil
+		}
 
-		if (rval < 1) || (rval > count) {
-			continue
+		if i := indexOf(list, result); i >= 0 {
+			return list[i], nil
 		}
 
-		return list[rval-1], nil
+		if !view.setFilter(result) {
+			c.Printf("No matches for '%s'\n", result)
+		}
 	}
 }
 
+// SelectMany asks for a set of items from list. Each response replaces
+// the selection and is a comma separated list of numbers, ranges (1-3)
+// and names, or the keywords "all" and "none". An empty response
+// accepts the current selection.
 func (c *Cli) SelectMany(varName string, list []string) ([]string, error) {
 	count := len(list)
 	if count <= 0 {
@@ -49,33 +85,25 @@ func (c *Cli) SelectMany(varName string, list []string) ([]string, error) {
 
 	selected := make([]bool, count)
 
-	prompt := fmt.Sprintf("Enter value for %s (1-%d)", varName, count+1)
+	prompt := fmt.Sprintf("Enter values for %s (e.g. 1-3,5, all, none)", varName)
+	if !c.Interactive() {
+		return nil, nonInteractiveError(prompt)
+	}
+
+	view := newListView(list)
 
 	for {
 		c.Println()
-		for i, item := range list {
-			marked := " "
-			if selected[i] {
-				marked = "*"
-			}
-			c.Printf("%s %2d %s\n", marked, i+1, item)
-		}
-		c.Printf("  %2d Done\n", count+1)
+		view.print(c, selected)
+		c.Println("Enter an empty line to accept the selection")
 
-		result, err := c.a.Prompt(prompt, checkIsNumeric, checkNotEmpty)
-		switch {
-		case isEmpty(err):
-			fallthrough
-		case isNotNumeric(err):
-			continue
-		case err != nil:
+		result, err := c.a.Prompt(prompt, checkBack)
+		if err != nil {
 			return nil, err
 		}
 
-		// String conversion errors are caught by the checkIsNumeric check
-		rval, _ := strconv.Atoi(result)
-
-		if rval == (count + 1) {
+		switch result {
+		case "":
 			rlist := []string{}
 			for i, isSelected := range selected {
 				if isSelected {
@@ -87,12 +115,179 @@ func (c *Cli) SelectMany(varName string, list []string) ([]string, error) {
 			}
 
 			return rlist, nil
+		case nextPage:
+			view.next()
+			continue
+		case prevPage:
+			view.prev()
+			continue
+		}
+
+		s, err := parseSelection(result, list)
+		if err != nil {
+			c.Println(err)
+			continue
+		}
+		selected = s
+	}
+}
+
+// parseSelection parses a SelectMany response
+func parseSelection(input string, list []string) ([]bool, error) {
+	count := len(list)
+	selected := make([]bool, count)
+
+	for _, token := range strings.Split(input, ",") {
+		token = strings.TrimSpace(token)
+
+		switch {
+		case token == "":
+			continue
+		case strings.EqualFold(token, selectAll):
+			for i := range selected {
+				selected[i] = true
+			}
+			continue
+		case strings.EqualFold(token, selectNone):
+			selected = make([]bool, count)
+			continue
 		}
 
-		if (rval < 1) || (rval > count) {
+		if i := indexOf(list, token); i >= 0 {
+			selected[i] = true
 			continue
 		}
 
-		selected[rval-1] = !selected[rval-1]
+		first, last, err := parseRange(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if (first < 1) || (last > count) || (first > last) {
+			return nil, fmt.Errorf("Invalid selection '%s'. Values must be between 1 and %d", token, count)
+		}
+
+		for i := first; i <= last; i++ {
+			selected[i-1] = true
+		}
+	}
+
+	return selected, nil
+}
+
+// parseRange parses "n" or "n-m"
+func parseRange(token string) (int, int, error) {
+	bounds := strings.SplitN(token, "-", 2)
+
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid selection '%s'", token)
+	}
+
+	if len(bounds) == 1 {
+		return first, first, nil
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid selection '%s'", token)
+	}
+
+	return first, last, nil
+}
+
+func indexOf(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// listView is the page of a list shown by Select and SelectMany.
+// Items keep their position in the full list when it is filtered so
+// numbers never change meaning.
+type listView struct {
+	list    []string
+	matches []int
+	filter  string
+	page    int
+}
+
+func newListView(list []string) *listView {
+	v := &listView{list: list}
+	v.setFilter("")
+
+	return v
+}
+
+// setFilter narrows the view to items containing f, ignoring case. The
+// view is left alone if nothing matches.
+func (v *listView) setFilter(f string) bool {
+	matches := []int{}
+	for i, item := range v.list {
+		if strings.Contains(strings.ToLower(item), strings.ToLower(f)) {
+			matches = append(matches, i)
+		}
+	}
+
+	if len(matches) == 0 {
+		return false
+	}
+
+	v.filter = f
+	v.matches = matches
+	v.page = 0
+
+	return true
+}
+
+func (v *listView) pages() int {
+	return (len(v.matches) + pageSize - 1) / pageSize
+}
+
+func (v *listView) next() {
+	if v.page < v.pages()-1 {
+		v.page++
+	}
+}
+
+func (v *listView) prev() {
+	if v.page > 0 {
+		v.page--
+	}
+}
+
+func (v *listView) print(c *Cli, selected []bool) {
+	if v.filter != "" {
+		c.Printf("Items matching '%s' (%d of %d)\n", v.filter, len(v.matches), len(v.list))
+	}
+
+	width := len(strconv.Itoa(len(v.list)))
+	if width < 2 {
+		width = 2
+	}
+
+	start := v.page * pageSize
+	end := start + pageSize
+	if end > len(v.matches) {
+		end = len(v.matches)
+	}
+
+	for _, i := range v.matches[start:end] {
+		if selected != nil {
+			marked := " "
+			if selected[i] {
+				marked = "*"
+			}
+			c.Printf("%s ", marked)
+		}
+		c.Printf("%*d %s\n", width, i+1, v.list[i])
+	}
+
+	if v.pages() > 1 {
+		c.Printf("Page %d of %d. Enter %s or %s to change pages\n", v.page+1, v.pages(), nextPage, prevPage)
 	}
 }
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -37,9 +39,19 @@ func TestSelect(t *testing.T) {
 		},
 		{
 			[]string{"Chris", "Jenny", "Ethan", "Emily"},
-			"Ethan\n3\n",
+			"Ethan\n",
 			"Ethan",
 		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"em\nEmily\n",
+			"Emily",
+		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"nomatch\n\n+\n-\n2\n",
+			"Jenny",
+		},
 	}
 
 	assert := assert.New(t)
@@ -55,3 +67,88 @@ func TestSelect(t *testing.T) {
 		assert.Equal(cse.Expected, result)
 	}
 }
+
+func TestSelect_paging(t *testing.T) {
+	assert := assert.New(t)
+
+	list := make([]string, 45)
+	for i := range list {
+		list[i] = fmt.Sprintf("zone-%02d", i+1)
+	}
+
+	rsp := new(bytes.Buffer)
+	out := new(bytes.Buffer)
+	c := New(rsp, out)
+
+	rsp.WriteString("+\n+\n+\nzone-45\n")
+	result, err := c.Select("test", list)
+	assert.Nil(err)
+	assert.Equal("zone-45", result)
+
+	assert.Contains(out.String(), "Page 1 of 3")
+	assert.Contains(out.String(), "Page 3 of 3")
+	assert.False(strings.Contains(out.String(), "Page 4 of 3"))
+}
+
+func TestListView_setFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	v := newListView([]string{"us-central1-a", "us-central1-b", "europe-west1-b"})
+	assert.Equal([]int{0, 1, 2}, v.matches)
+
+	assert.True(v.setFilter("CENTRAL"))
+	assert.Equal([]int{0, 1}, v.matches)
+
+	assert.False(v.setFilter("asia"))
+	assert.Equal([]int{0, 1}, v.matches)
+
+	assert.True(v.setFilter(""))
+	assert.Equal([]int{0, 1, 2}, v.matches)
+}
+
+func TestSelectMany(t *testing.T) {
+	cases := []struct {
+		Options  []string
+		Input    string
+		Expected []string
+	}{
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"1-3\n\n",
+			[]string{"Chris", "Jenny", "Ethan"},
+		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"1,3-4\n\n",
+			[]string{"Chris", "Ethan", "Emily"},
+		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"all\n\n",
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"\nall\nnone\n\n2, Emily\n\n",
+			[]string{"Jenny", "Emily"},
+		},
+		{
+			[]string{"Chris", "Jenny", "Ethan", "Emily"},
+			"5\n3-1\nx\n4\n\n",
+			[]string{"Emily"},
+		},
+	}
+
+	assert := assert.New(t)
+
+	rsp := new(bytes.Buffer)
+	c := New(rsp, ioutil.Discard)
+
+	for _, cse := range cases {
+		rsp.Reset()
+		rsp.WriteString(cse.Input)
+		result, err := c.SelectMany("test", cse.Options)
+		assert.Nil(err)
+		assert.Equal(cse.Expected, result)
+	}
+}
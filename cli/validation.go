@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"github.com/deiwin/interact"
+)
+
+// Check validates a response. When a check fails the error is shown
+// and the question is asked again.
+type Check func(string) error
+
+type ValidationError struct {
+	err error
+}
+
+func (e *ValidationError) Error() string { return e.err.Error() }
+
+func isInvalid(err error) bool {
+	switch err.(type) {
+	case *ValidationError:
+		return true
+	}
+
+	return false
+}
+
+// toInputChecks wraps each check so a failure can be told apart from
+// an input error
+func toInputChecks(checks []Check) []interact.InputCheck {
+	rval := make([]interact.InputCheck, len(checks))
+	for i, check := range checks {
+		check := check
+		rval[i] = func(input string) error {
+			if err := check(input); err != nil {
+				return &ValidationError{err: err}
+			}
+			return nil
+		}
+	}
+
+	return rval
+}
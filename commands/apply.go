@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addApplySub() {
+	aCmd := &cobra.Command{
+		Use:   "apply <planfile>",
+		Short: "Apply a saved plan",
+		Long:  "Apply a plan saved with 'pony plan --out' exactly as it was shown, without prompting",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("apply takes exactly one plan file")
+			}
+
+			return c.tf.ApplyPlan(args[0])
+		},
+	}
+
+	c.root.AddCommand(aCmd)
+}
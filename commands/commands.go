@@ -1,7 +1,13 @@
 package commands
 
 import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/asteris-llc/pony/cli"
 	"github.com/asteris-llc/pony/tf"
+	"github.com/asteris-llc/pony/tf/backend"
 	"github.com/asteris-llc/pony/tf/plugin"
 
 	log "github.com/sirupsen/logrus"
@@ -9,9 +15,23 @@ import (
 )
 
 type Command struct {
-	root     *cobra.Command
-	tf       *tf.Tf
-	logLevel string
+	root        *cobra.Command
+	tf          *tf.Tf
+	logLevel    string
+	answersFile string
+	recordFile  string
+	vars        stringList
+	varFiles    stringList
+	showSources bool
+	history     int
+	stateFile   string
+	stateOut    string
+	lockTimeout time.Duration
+	backend     string
+	backendConf stringList
+	encrypt     bool
+	keyFile     string
+	env         string
 }
 
 func Init() *Command {
@@ -24,24 +44,41 @@ func Init() *Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			c.configureLogging()
-
-			return nil
+			return c.setup()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := c.tf.SelectCloud(); err != nil {
-				return err
-			}
-
 			return c.tf.Create()
 		},
 	}
 	c.tf = tf.New()
 
 	c.root.PersistentFlags().StringVarP(&c.logLevel, "log-level", "l", "warn", "Logging level")
+	c.root.PersistentFlags().StringVarP(&c.answersFile, "answers", "a", "", "Read answers from a YAML or JSON file instead of prompting")
+	c.root.PersistentFlags().StringVar(&c.recordFile, "record", "", "Write every answer to a file that can be replayed with --answers")
+	c.root.PersistentFlags().Var(&c.vars, "var", "Set a variable: [module.]name=value. May be repeated")
+	c.root.PersistentFlags().Var(&c.varFiles, "var-file", "Read variables from a .tfvars file. May be repeated")
+	c.root.PersistentFlags().BoolVar(&c.showSources, "show-sources", false, "Print where each variable value came from")
+	c.root.PersistentFlags().StringVarP(&c.stateFile, "state", "s", defaultStatePath(), "Path to environment state. Defaults to $"+tf.StateEnv)
+	c.root.PersistentFlags().StringVarP(&c.env, "env", "e", os.Getenv(tf.EnvEnv), "Environment to use instead of the selected one. Defaults to $"+tf.EnvEnv)
+	c.root.PersistentFlags().StringVar(&c.stateOut, "state-out", "", "Write the state to this file instead of --state")
+	c.root.PersistentFlags().DurationVar(&c.lockTimeout, "lock-timeout", 0, "How long to wait for the state lock, e.g. 30s")
+	c.root.PersistentFlags().IntVar(&c.history, "state-history", 0, "Number of previous states to keep in the history directory")
+	c.root.PersistentFlags().StringVar(&c.backend, "backend", "local", "Where the state is stored: "+strings.Join(backend.Types(), ", "))
+	c.root.PersistentFlags().Var(&c.backendConf, "backend-config", "Backend setting key=value or a YAML file of settings. May be repeated")
+	c.root.PersistentFlags().BoolVar(&c.encrypt, "encrypt-state", false, "Encrypt the state with a passphrase from $"+tf.PassphraseEnv+" or a prompt")
+	c.root.PersistentFlags().StringVar(&c.keyFile, "state-key-file", os.Getenv(tf.StateKeyFileEnv), "Encrypt the state with the contents of this file. Defaults to $"+tf.StateKeyFileEnv)
 
 	plugin.InitPluginCmd(c.root)
 	c.addDestroySub()
+	c.addPlanSub()
+	c.addApplySub()
+	c.addStatusSub()
+	c.addInventorySub()
+	c.addSSHSub()
+	c.addScaleSub()
+	c.addStateSub()
+	c.addForceUnlockSub()
+	c.addEnvSub()
 
 	return &c
 }
@@ -53,6 +90,108 @@ func (c *Command) Execute() {
 	}
 }
 
+func defaultStatePath() string {
+	if path := os.Getenv(tf.StateEnv); path != "" {
+		return path
+	}
+
+	return tf.StatePath
+}
+
+// setup applies the persistent flags. It runs before every command
+// except the plugin and most env commands.
+func (c *Command) setup() error {
+	c.configureLogging()
+	c.tf.HandleInterrupts()
+
+	if c.answersFile != "" {
+		a, err := cli.ReadAnswers(c.answersFile)
+		if err != nil {
+			return err
+		}
+		c.tf.SetAnswers(a)
+	}
+
+	if c.recordFile != "" {
+		c.tf.SetRecord(c.recordFile)
+	}
+
+	for _, f := range c.varFiles {
+		if err := c.tf.AddVarFile(f); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range c.vars {
+		if err := c.tf.SetVar(v); err != nil {
+			return err
+		}
+	}
+
+	c.tf.SetShowSources(c.showSources)
+	c.tf.SetStateHistory(c.history)
+	c.tf.SetStatePath(c.stateFile)
+
+	if err := c.useEnv(); err != nil {
+		return err
+	}
+
+	c.tf.SetStateOut(c.stateOut)
+	c.tf.SetLockTimeout(c.lockTimeout)
+	c.tf.SetStateEncryption(c.encrypt, c.keyFile)
+
+	config, err := backendConfig(c.backendConf)
+	if err != nil {
+		return err
+	}
+	if err := c.tf.SetBackend(c.backend, config); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// useEnv points the state and the recorded answers at the environment
+// unless --state, $PONY_STATE or --record say otherwise. The answers
+// recorded in the environment are replayed unless --answers is given.
+func (c *Command) useEnv() error {
+	env := c.env
+	if env == "" {
+		current, err := tf.CurrentEnv()
+		if err != nil {
+			return err
+		}
+		env = current
+	}
+	if env == "" {
+		return nil
+	}
+
+	if err := c.tf.SetEnv(env); err != nil {
+		return err
+	}
+
+	if !c.root.PersistentFlags().Changed("state") && os.Getenv(tf.StateEnv) == "" {
+		c.tf.SetStatePath(c.tf.EnvStatePath())
+	}
+
+	if c.answersFile == "" {
+		a, err := c.tf.EnvAnswers()
+		if err != nil {
+			return err
+		}
+		if a != nil {
+			c.tf.SetAnswers(a)
+		}
+	}
+
+	if c.recordFile == "" {
+		c.tf.SetRecord(c.tf.EnvAnswersPath())
+	}
+
+	return nil
+}
+
 func (c *Command) configureLogging() {
 	l, err := log.ParseLevel(c.logLevel)
 	if err != nil {
@@ -1,24 +1,27 @@
 package commands
 
 import (
-	"github.com/asteris-llc/pony/tf"
-
 	"github.com/spf13/cobra"
 )
 
 func (c *Command) addDestroySub() {
-	var stateFile string
+	var roles, nodes stringList
+	var yes bool
 
 	dCmd := &cobra.Command{
 		Use:   "destroy",
 		Short: "Destroy infrastructure",
-		Long:  "Destroy infrastructure",
+		Long: `Destroy infrastructure. With --role or --node only those nodes and the
+disks attached to them are destroyed. The plan is shown and must be
+confirmed first.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.tf.Destroy(stateFile)
+			return c.tf.Destroy(roles, nodes, yes)
 		},
 	}
 
-	dCmd.Flags().StringVarP(&stateFile, "state", "s", tf.StatePath, "Path to environment state")
+	dCmd.Flags().Var(&roles, "role", "Destroy only the nodes of a role. May be repeated")
+	dCmd.Flags().Var(&nodes, "node", "Destroy only a node, by name or role-index. May be repeated")
+	dCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Destroy the selected nodes without asking for confirmation")
 
 	c.root.AddCommand(dCmd)
 }
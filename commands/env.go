@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/asteris-llc/pony/tf"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addEnvSub() {
+	eCmd := &cobra.Command{
+		Use:   "env",
+		Short: "Manage environments",
+		Long: `Manage named environments such as dev, staging and prod. Each one keeps
+its own state, recorded answers and short_name default in ` + tf.EnvDir + `/<name>/.
+The selected environment is used by every command unless --env or $` + tf.EnvEnv + `
+chooses another. Its recorded answers are replayed unless --answers is given.`,
+		// The environment commands work on the environments themselves
+		// and do not need a selected one
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			c.configureLogging()
+			return nil
+		},
+	}
+
+	c.addEnvNewSub(eCmd)
+	c.addEnvSelectSub(eCmd)
+	c.addEnvListSub(eCmd)
+	c.addEnvDeleteSub(eCmd)
+
+	c.root.AddCommand(eCmd)
+}
+
+func (c *Command) addEnvNewSub(parent *cobra.Command) {
+	var cloud, project string
+	var sel bool
+
+	nCmd := &cobra.Command{
+		Use:   "new <name>",
+		Short: "Create an environment",
+		Long: `Create an environment. Its cloud and project are pinned by --cloud and
+--project or by the first cluster created in it. Commands refuse to use a
+state or answers for another cloud or project.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("new takes exactly one environment name")
+			}
+
+			if err := tf.NewEnv(args[0], cloud, project); err != nil {
+				return err
+			}
+
+			if !sel {
+				return nil
+			}
+
+			return tf.SelectEnv(args[0])
+		},
+	}
+
+	nCmd.Flags().StringVar(&cloud, "cloud", "", "Pin the environment to a cloud")
+	nCmd.Flags().StringVar(&project, "project", "", "Pin the environment to a project")
+	nCmd.Flags().BoolVar(&sel, "select", true, "Select the new environment")
+
+	parent.AddCommand(nCmd)
+}
+
+func (c *Command) addEnvSelectSub(parent *cobra.Command) {
+	sCmd := &cobra.Command{
+		Use:   "select <name>",
+		Short: "Select the environment used by later commands",
+		Long:  "Select the environment used by later commands",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("select takes exactly one environment name")
+			}
+
+			return tf.SelectEnv(args[0])
+		},
+	}
+
+	parent.AddCommand(sCmd)
+}
+
+func (c *Command) addEnvListSub(parent *cobra.Command) {
+	lCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the environments",
+		Long:  "List the environments and what they are pinned to. The selected one is marked with *.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tf.ListEnvs()
+		},
+	}
+
+	parent.AddCommand(lCmd)
+}
+
+func (c *Command) addEnvDeleteSub(parent *cobra.Command) {
+	var yes bool
+
+	dCmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete an environment",
+		Long: `Delete an environment and its state history. Its cluster must be destroyed
+first. The state is checked with the same --backend and encryption
+settings as every other command.`,
+		// Unlike the other env commands delete reads the state of the
+		// environment, so it needs the full setup for it
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("delete takes exactly one environment name")
+			}
+			c.env = args[0]
+
+			return c.setup()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.tf.DeleteEnv(yes)
+		},
+	}
+
+	dCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete without asking for confirmation")
+
+	parent.AddCommand(dCmd)
+}
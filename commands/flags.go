@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// stringList is a repeatable string flag. Unlike pflag's StringSlice it
+// does not split values on commas, so --var zones=a,b keeps its value.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func (s *stringList) Type() string {
+	return "stringList"
+}
+
+// backendConfig merges --backend-config values. Each is key=value or
+// the path of a YAML file of keys and values.
+func backendConfig(list stringList) (map[string]string, error) {
+	config := make(map[string]string)
+
+	for _, v := range list {
+		if i := strings.Index(v, "="); i > 0 {
+			config[v[:i]] = v[i+1:]
+			continue
+		}
+
+		data, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid backend config '%s'. Expected key=value or a file: %s", v, err)
+		}
+
+		values := make(map[string]string)
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("Error parsing %s: %s", v, err)
+		}
+
+		for k, val := range values {
+			config[k] = val
+		}
+	}
+
+	return config, nil
+}
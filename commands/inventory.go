@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"github.com/asteris-llc/pony/tf"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addInventorySub() {
+	var format string
+	var list bool
+	var host string
+
+	iCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Print an ansible inventory of the cluster",
+		Long: `Print an ansible inventory of the cluster built from the saved state.
+Hosts are grouped by role=<role> and dc=<datacenter>.
+
+With --list or --host the output is that of an ansible dynamic inventory
+script. Call it from a script such as:
+
+  #!/bin/sh
+  exec pony inventory --state /path/to/pony.state "$@"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case list:
+				return c.tf.InventoryList()
+			case host != "":
+				return c.tf.InventoryHost(host)
+			}
+
+			return c.tf.Inventory(format)
+		},
+	}
+
+	iCmd.Flags().StringVarP(&format, "format", "f", tf.InventoryINI, "Static inventory format: ini or yaml")
+	iCmd.Flags().BoolVar(&list, "list", false, "Print all groups as a dynamic inventory")
+	iCmd.Flags().StringVar(&host, "host", "", "Print the variables of one host as a dynamic inventory")
+
+	c.root.AddCommand(iCmd)
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addPlanSub() {
+	var out string
+
+	pCmd := &cobra.Command{
+		Use:   "plan [--out <planfile>]",
+		Short: "Show the changes create would make",
+		Long: `Ask for the configuration like create and show the changes to every
+resource without applying them. With --out the plan is saved for
+'pony apply':
+
+  pony plan --out cluster.plan`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("plan takes no arguments. Save the plan with --out <planfile>")
+			}
+
+			return c.tf.ShowPlan(out)
+		},
+	}
+
+	pCmd.Flags().StringVar(&out, "out", "", "Save the plan to a file for 'pony apply'")
+
+	c.root.AddCommand(pCmd)
+}
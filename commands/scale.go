@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addScaleSub() {
+	var role string
+	var count int
+	var yes bool
+
+	sCmd := &cobra.Command{
+		Use:   "scale",
+		Short: "Change the number of nodes of a role",
+		Long:  "Change the number of nodes of a role in an existing cluster. Only the nodes being added or removed are changed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if role == "" || count <= 0 {
+				return fmt.Errorf("--role and a --count of at least 1 are required")
+			}
+
+			return c.tf.Scale(role, count, yes)
+		},
+	}
+
+	sCmd.Flags().StringVar(&role, "role", "", "Role to scale: control, edge or worker")
+	sCmd.Flags().IntVar(&count, "count", 0, "New number of nodes")
+	sCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Apply without asking for confirmation")
+
+	c.root.AddCommand(sCmd)
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"github.com/asteris-llc/pony/tf"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addSSHSub() {
+	var key string
+	var role string
+
+	sCmd := &cobra.Command{
+		Use:   "ssh <node> [-- command]",
+		Short: "Connect to a cluster node",
+		Long: `Connect to a cluster node with the local ssh client. The node is
+given by name (mantl-worker-02), role and number (worker-02) or role and
+index (control 1).
+
+With --all-role the command after -- is run on every node of the role in
+parallel:
+
+  pony ssh --all-role worker -- uptime`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, command := args, []string{}
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				target, command = args[:dash], args[dash:]
+			}
+
+			if role != "" {
+				return c.tf.SSHRole(key, role, append(target, command...))
+			}
+
+			return c.tf.SSH(key, target, command)
+		},
+	}
+
+	sCmd.Flags().StringVarP(&key, "identity", "i", "", "Private key used to connect (default: the cluster's ssh_key without .pub, or "+tf.DefaultSSHKey+")")
+	sCmd.Flags().StringVar(&role, "all-role", "", "Run the command on every node of a role")
+
+	c.root.AddCommand(sCmd)
+}
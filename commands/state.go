@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/asteris-llc/pony/tf"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addStateSub() {
+	sCmd := &cobra.Command{
+		Use:   "state",
+		Short: "Manage the state file",
+		Long:  "Manage the state file",
+	}
+
+	c.addStateRestoreSub(sCmd)
+	c.addStateRekeySub(sCmd)
+
+	c.root.AddCommand(sCmd)
+}
+
+func (c *Command) addStateRestoreSub(parent *cobra.Command) {
+	var yes bool
+
+	rCmd := &cobra.Command{
+		Use:   "restore [backup|<history file>]",
+		Short: "Roll the state back to a previous version",
+		Long: `Roll the state back to its backup or a file in its history. Without an
+argument the versions are listed to choose from. The current state
+becomes the new backup so a restore can be undone.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("restore takes at most one version")
+			}
+
+			from := ""
+			if len(args) == 1 {
+				from = args[0]
+			}
+
+			return c.tf.RestoreState(from, yes)
+		},
+	}
+
+	rCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Restore without asking for confirmation")
+
+	parent.AddCommand(rCmd)
+}
+
+func (c *Command) addStateRekeySub(parent *cobra.Command) {
+	var keyFile string
+	var decrypt bool
+
+	rCmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Change the key the state is encrypted with",
+		Long: `Decrypt the state with its current key and encrypt it again with a new
+passphrase, read from $` + tf.NewPassphraseEnv + ` or asked for, or with a new key file.
+With --decrypt the state is written in the clear. Also encrypts a state
+that is not encrypted yet.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("rekey takes no arguments")
+			}
+
+			return c.tf.RekeyState(keyFile, decrypt)
+		},
+	}
+
+	rCmd.Flags().StringVar(&keyFile, "new-key-file", "", "Encrypt the state with the contents of this file")
+	rCmd.Flags().BoolVar(&decrypt, "decrypt", false, "Remove the encryption")
+
+	parent.AddCommand(rCmd)
+}
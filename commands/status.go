@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addStatusSub() {
+	var asJSON bool
+
+	sCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the nodes of the cluster",
+		Long:  "Show the nodes of the cluster grouped by role from the saved state",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.tf.Status(asJSON)
+		},
+	}
+
+	sCmd.Flags().BoolVar(&asJSON, "json", false, "Print the status as JSON")
+
+	c.root.AddCommand(sCmd)
+}
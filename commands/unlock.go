@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *Command) addForceUnlockSub() {
+	uCmd := &cobra.Command{
+		Use:   "force-unlock <lock id>",
+		Short: "Remove a stale state lock",
+		Long: `Remove the lock of a run that no longer exists. The lock ID is shown in
+the error of the run that found the state locked. Never remove the lock
+of a run that is still going.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("force-unlock takes exactly one lock ID")
+			}
+
+			return c.tf.ForceUnlock(args[0])
+		},
+	}
+
+	c.root.AddCommand(uCmd)
+}
@@ -0,0 +1,228 @@
+// Package backend stores pony state files locally or in a remote
+// service so a cluster can be managed from more than one machine.
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backend reads, writes and locks one serialized state
+type Backend interface {
+	// Read returns nil if there is no state yet
+	Read() ([]byte, error)
+	Write(data []byte) error
+
+	// Lock returns a LockedError if the lock is held. Locks are
+	// advisory: they only keep pony runs from overlapping.
+	Lock(info *LockInfo) error
+	Unlock(id string) error
+
+	// LockInfo returns nil if the state is not locked and
+	// ErrNoLockInfo if the backend cannot read its locks
+	LockInfo() (*LockInfo, error)
+
+	// String describes where the state is stored
+	String() string
+}
+
+const envPlaceholder = "{env}"
+
+var ErrNoLockInfo = errors.New("The backend cannot read its lock")
+
+type factory func(config map[string]string) (Backend, error)
+
+var backends = map[string]factory{
+	"local":  newLocal,
+	"http":   newHTTP,
+	"consul": newConsul,
+	"s3":     newS3,
+	"gcs":    newGCS,
+}
+
+// Types returns the names of the available backends
+func Types() []string {
+	rval := make([]string, 0, len(backends))
+	for k := range backends {
+		rval = append(rval, k)
+	}
+	sort.Strings(rval)
+
+	return rval
+}
+
+// New returns a backend configured with the --backend-config values
+func New(name string, config map[string]string) (Backend, error) {
+	f, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown backend '%s'. Backends: %s", name, strings.Join(Types(), ", "))
+	}
+
+	b, err := f(config)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s backend configuration: %s", name, err)
+	}
+
+	return b, nil
+}
+
+// LockInfo identifies the holder of a state lock
+type LockInfo struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	Holder    string    `json:"holder"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Created   time.Time `json:"created"`
+}
+
+// UnknownLockID is the ID of a lock whose holder cannot be read
+const UnknownLockID = "unknown"
+
+func NewLockInfo(operation string) (*LockInfo, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	holder := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		holder = u.Username
+	}
+
+	host, _ := os.Hostname()
+
+	return &LockInfo{
+		ID:        hex.EncodeToString(buf),
+		Operation: operation,
+		Holder:    holder,
+		Host:      host,
+		PID:       os.Getpid(),
+		Created:   time.Now().UTC(),
+	}, nil
+}
+
+// parseLockInfo reads a stored lock. A lock left empty or cut short by
+// a crashed run, or written by another tool, still locks the state, by
+// an unknown holder that force-unlock can remove.
+func parseLockInfo(data []byte) *LockInfo {
+	info := new(LockInfo)
+	if err := json.Unmarshal(data, info); err != nil || info.ID == "" {
+		return &LockInfo{ID: UnknownLockID}
+	}
+
+	return info
+}
+
+func (i *LockInfo) String() string {
+	if i.ID == UnknownLockID {
+		return "an unknown run"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d) for %s since %s",
+		i.Holder,
+		i.Host,
+		i.PID,
+		i.Operation,
+		i.Created.Local().Format(time.RFC1123),
+	)
+}
+
+// LockedError is returned when another run holds the lock
+type LockedError struct {
+	Info *LockInfo
+}
+
+func (e *LockedError) Error() string {
+	if e.Info == nil {
+		return "State is locked by another run"
+	}
+
+	return fmt.Sprintf("State is locked by %s. If the lock is stale remove it with 'pony force-unlock %s'", e.Info, e.Info.ID)
+}
+
+func IsLocked(err error) bool {
+	switch err.(type) {
+	case *LockedError:
+		return true
+	}
+
+	return false
+}
+
+func mismatchError(id string, held *LockInfo) error {
+	return fmt.Errorf("Lock ID '%s' does not match the lock held by %s (%s)", id, held, held.ID)
+}
+
+func required(config map[string]string, keys ...string) error {
+	for _, k := range keys {
+		if config[k] == "" {
+			return fmt.Errorf("'%s' is required", k)
+		}
+	}
+
+	return nil
+}
+
+func withDefault(config map[string]string, key, def string) string {
+	if v := config[key]; v != "" {
+		return v
+	}
+
+	return def
+}
+
+// ForEnv returns the configuration of a backend for one environment so
+// environments never share a remote state:
+//
+//   - http: {env} in the addresses is replaced by the environment,
+//     otherwise it is appended to their path
+//   - consul: the path becomes <path>-env:<env>
+//   - s3 and gcs: the key becomes env:/<env>/<key>
+//
+// The local backend is left alone. Its path is chosen by the caller.
+func ForEnv(name string, config map[string]string, env string) (map[string]string, error) {
+	rval := make(map[string]string, len(config))
+	for k, v := range config {
+		rval[k] = v
+	}
+
+	if env == "" {
+		return rval, nil
+	}
+
+	switch name {
+	case "http":
+		for _, k := range []string{"address", "lock_address", "unlock_address"} {
+			if v := rval[k]; v != "" {
+				u, err := url.Parse(v)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid %s '%s': %s", k, v, err)
+				}
+				if strings.Contains(v, envPlaceholder) {
+					rval[k] = strings.Replace(v, envPlaceholder, env, -1)
+				} else {
+					u.Path = strings.TrimSuffix(u.Path, "/") + "/" + env
+					rval[k] = u.String()
+				}
+			}
+		}
+	case "consul":
+		if p := rval["path"]; p != "" {
+			rval["path"] = strings.TrimSuffix(p, "/") + "-env:" + env
+		}
+	case "s3", "gcs":
+		rval["key"] = "env:/" + env + "/" + strings.TrimPrefix(withDefault(rval, "key", defaultKey), "/")
+	}
+
+	return rval, nil
+}
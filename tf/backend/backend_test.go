@@ -0,0 +1,340 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBackend runs the same reads, writes and locks against any backend
+func testBackend(t *testing.T, b Backend, hasLockInfo bool) {
+	assert := assert.New(t)
+
+	data, err := b.Read()
+	assert.NoError(err)
+	assert.Nil(data)
+
+	assert.NoError(b.Write([]byte("first")))
+	assert.NoError(b.Write([]byte("second")))
+
+	data, err = b.Read()
+	assert.NoError(err)
+	assert.Equal("second", string(data))
+
+	first, err := NewLockInfo("test")
+	assert.NoError(err)
+	second, err := NewLockInfo("test")
+	assert.NoError(err)
+
+	assert.NoError(b.Lock(first))
+
+	err = b.Lock(second)
+	assert.True(IsLocked(err), err)
+
+	if hasLockInfo {
+		assert.Equal(first.ID, err.(*LockedError).Info.ID)
+
+		info, err := b.LockInfo()
+		assert.NoError(err)
+		assert.Equal(first.ID, info.ID)
+	}
+
+	assert.Error(b.Unlock(second.ID))
+	assert.NoError(b.Unlock(first.ID))
+
+	if hasLockInfo {
+		info, err := b.LockInfo()
+		assert.NoError(err)
+		assert.Nil(info)
+	}
+
+	assert.NoError(b.Lock(second))
+	assert.NoError(b.Unlock(second.ID))
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New("ftp", nil)
+	assert.Error(err)
+
+	_, err = New("s3", map[string]string{})
+	assert.Error(err)
+
+	b, err := New("gcs", map[string]string{"bucket": "b"})
+	assert.NoError(err)
+	assert.Equal("gs://b/pony.state", b.String())
+}
+
+func TestLocal(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "pony")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pony.state")
+	b, err := New("local", map[string]string{"path": path, "history": "1"})
+	assert.NoError(err)
+
+	testBackend(t, b, true)
+
+	backup, err := ioutil.ReadFile(path + BackupSuffix)
+	assert.NoError(err)
+	assert.Equal("first", string(backup))
+
+	assert.NoError(b.Write([]byte("third")))
+	versions, err := b.(*Local).Versions()
+	assert.NoError(err)
+	assert.Equal(2, len(versions))
+}
+
+// testUnreadableLock checks that a lock that cannot be read still
+// locks the state and can be removed with its unknown ID
+func testUnreadableLock(t *testing.T, b Backend) {
+	assert := assert.New(t)
+
+	info, err := NewLockInfo("test")
+	assert.NoError(err)
+
+	err = b.Lock(info)
+	if !assert.True(IsLocked(err), err) {
+		return
+	}
+	assert.Equal(UnknownLockID, err.(*LockedError).Info.ID)
+
+	held, err := b.LockInfo()
+	assert.NoError(err)
+	assert.Equal(UnknownLockID, held.ID)
+
+	assert.Error(b.Unlock(info.ID))
+	assert.NoError(b.Unlock(UnknownLockID))
+	assert.NoError(b.Lock(info))
+	assert.NoError(b.Unlock(info.ID))
+}
+
+func TestLocal_UnreadableLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pony")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b := &Local{Path: filepath.Join(dir, "pony.state")}
+	assert.NoError(t, ioutil.WriteFile(b.Path+LockSuffix, nil, 0600))
+
+	testUnreadableLock(t, b)
+}
+
+// memStore is the state of a fake server
+type memStore struct {
+	l       sync.Mutex
+	objects map[string][]byte
+	index   uint64
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: make(map[string][]byte)}
+}
+
+func TestHTTP(t *testing.T) {
+	m := newMemStore()
+	var lock []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.l.Lock()
+		defer m.l.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch r.Method {
+		case "GET":
+			if data, ok := m.objects["state"]; ok {
+				w.Write(data)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case "POST":
+			m.objects["state"] = body
+		case "LOCK":
+			if lock != nil {
+				w.WriteHeader(http.StatusLocked)
+				w.Write(lock)
+				return
+			}
+			lock = body
+		case "UNLOCK":
+			var held, info LockInfo
+			json.Unmarshal(lock, &held)
+			json.Unmarshal(body, &info)
+			if lock != nil && held.ID != info.ID {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			lock = nil
+		}
+	}))
+	defer srv.Close()
+
+	b, err := New("http", map[string]string{"address": srv.URL})
+	assert.NoError(t, err)
+
+	testBackend(t, b, false)
+}
+
+// consulHandler fakes the parts of the Consul KV API the backend uses
+func consulHandler(m *memStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.l.Lock()
+		defer m.l.Unlock()
+
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		body, _ := ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+		cas := q.Get("cas")
+
+		data, exists := m.objects[key]
+		index := m.objects[key+"#index"]
+
+		switch r.Method {
+		case "GET":
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if _, ok := q["raw"]; ok {
+				w.Write(data)
+				return
+			}
+			fmt.Fprintf(w, `[{"ModifyIndex":%s,"Value":"%s"}]`, index, base64.StdEncoding.EncodeToString(data))
+		case "PUT":
+			if cas == "0" && exists {
+				w.Write([]byte("false"))
+				return
+			}
+			m.index++
+			m.objects[key] = body
+			m.objects[key+"#index"] = []byte(fmt.Sprint(m.index))
+			w.Write([]byte("true"))
+		case "DELETE":
+			if cas != "" && cas != string(index) {
+				w.Write([]byte("false"))
+				return
+			}
+			delete(m.objects, key)
+			delete(m.objects, key+"#index")
+			w.Write([]byte("true"))
+		}
+	}
+}
+
+func TestConsul(t *testing.T) {
+	m := newMemStore()
+	srv := httptest.NewServer(consulHandler(m))
+	defer srv.Close()
+
+	b, err := New("consul", map[string]string{
+		"address": strings.TrimPrefix(srv.URL, "http://"),
+		"path":    "pony/test",
+	})
+	assert.NoError(t, err)
+
+	testBackend(t, b, true)
+
+	m.objects["pony/test/.lock"] = []byte("not a lock")
+	m.objects["pony/test/.lock#index"] = []byte("99")
+	testUnreadableLock(t, b)
+}
+
+func TestS3(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newMemStore()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.l.Lock()
+		defer m.l.Unlock()
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		key := r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		data, exists := m.objects[key]
+
+		switch r.Method {
+		case "GET":
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case "PUT":
+			if r.Header.Get("If-None-Match") == "*" && exists {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			m.objects[key] = body
+		case "DELETE":
+			delete(m.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	b, err := New("s3", map[string]string{
+		"bucket":     "pony",
+		"key":        "test/pony.state",
+		"endpoint":   srv.URL,
+		"access_key": "key",
+		"secret_key": "secret",
+	})
+	assert.NoError(err)
+
+	testBackend(t, b, true)
+
+	_, ok := m.objects["/pony/test/pony.state"]
+	assert.True(ok)
+
+	m.objects["/pony/test/pony.state.lock"] = []byte(`{"operation": "written by another tool"}`)
+	testUnreadableLock(t, b)
+}
+
+func TestForEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := ForEnv("http", map[string]string{"address": "https://state.example.com/pony/"}, "dev")
+	assert.NoError(err)
+	assert.Equal("https://state.example.com/pony/dev", c["address"])
+
+	c, err = ForEnv("http", map[string]string{"address": "https://state.example.com/{env}/state"}, "dev")
+	assert.NoError(err)
+	assert.Equal("https://state.example.com/dev/state", c["address"])
+
+	c, err = ForEnv("consul", map[string]string{"path": "pony/state"}, "prod")
+	assert.NoError(err)
+	assert.Equal("pony/state-env:prod", c["path"])
+
+	c, err = ForEnv("s3", map[string]string{"bucket": "b"}, "prod")
+	assert.NoError(err)
+	assert.Equal("env:/prod/pony.state", c["key"])
+
+	config := map[string]string{"bucket": "b", "key": "mantl/pony.state"}
+	c, err = ForEnv("gcs", config, "")
+	assert.NoError(err)
+	assert.Equal("mantl/pony.state", c["key"])
+
+	c, err = ForEnv("gcs", config, "dev")
+	assert.NoError(err)
+	assert.Equal("env:/dev/mantl/pony.state", c["key"])
+	assert.Equal("mantl/pony.state", config["key"])
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Consul stores the state in the Consul KV store. The lock is the key
+// <path>/.lock, created with check-and-set so only one run can take it.
+type Consul struct {
+	Address    string
+	Scheme     string
+	Path       string
+	Token      string
+	Datacenter string
+
+	Client *http.Client
+}
+
+func newConsul(config map[string]string) (Backend, error) {
+	if err := required(config, "path"); err != nil {
+		return nil, err
+	}
+
+	return &Consul{
+		Address:    withDefault(config, "address", "127.0.0.1:8500"),
+		Scheme:     withDefault(config, "scheme", "http"),
+		Path:       strings.Trim(config["path"], "/"),
+		Token:      config["access_token"],
+		Datacenter: config["datacenter"],
+		Client:     http.DefaultClient,
+	}, nil
+}
+
+func (c *Consul) String() string {
+	return fmt.Sprintf("consul://%s/%s", c.Address, c.Path)
+}
+
+func (c *Consul) lockPath() string {
+	return c.Path + "/.lock"
+}
+
+func (c *Consul) Read() ([]byte, error) {
+	return c.get(c.Path)
+}
+
+func (c *Consul) Write(data []byte) error {
+	ok, err := c.put(c.Path, data, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Consul refused to write %s", c.Path)
+	}
+
+	return nil
+}
+
+func (c *Consul) Lock(info *LockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// cas=0 only creates the key if it does not exist
+	ok, err := c.put(c.lockPath(), data, url.Values{"cas": {"0"}})
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	held, err := c.LockInfo()
+	if err != nil {
+		return err
+	}
+
+	return &LockedError{Info: held}
+}
+
+func (c *Consul) Unlock(id string) error {
+	rsp, body, err := c.do("GET", c.lockPath(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return statusError("GET", c.lockPath(), rsp, body)
+	}
+
+	var entries []struct {
+		ModifyIndex uint64
+		Value       string
+	}
+	if err := json.Unmarshal(body, &entries); err != nil || len(entries) != 1 {
+		return fmt.Errorf("Invalid response reading %s", c.lockPath())
+	}
+
+	value, err := base64.StdEncoding.DecodeString(entries[0].Value)
+	if err != nil {
+		return err
+	}
+
+	held := parseLockInfo(value)
+	if held.ID != id {
+		return mismatchError(id, held)
+	}
+
+	// Only delete the lock that was read
+	index := strconv.FormatUint(entries[0].ModifyIndex, 10)
+	rsp, body, err = c.do("DELETE", c.lockPath(), nil, url.Values{"cas": {index}})
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return statusError("DELETE", c.lockPath(), rsp, body)
+	}
+	if !isTrue(body) {
+		return fmt.Errorf("%s changed while it was being released", c.lockPath())
+	}
+
+	return nil
+}
+
+func (c *Consul) LockInfo() (*LockInfo, error) {
+	data, err := c.get(c.lockPath())
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return parseLockInfo(data), nil
+}
+
+func (c *Consul) get(key string) ([]byte, error) {
+	rsp, body, err := c.do("GET", key, nil, url.Values{"raw": {""}})
+	if err != nil {
+		return nil, err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+
+	return nil, statusError("GET", key, rsp, body)
+}
+
+// put returns Consul's true or false answer to a write
+func (c *Consul) put(key string, data []byte, q url.Values) (bool, error) {
+	rsp, body, err := c.do("PUT", key, data, q)
+	if err != nil {
+		return false, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return false, statusError("PUT", key, rsp, body)
+	}
+
+	return isTrue(body), nil
+}
+
+func (c *Consul) do(method, key string, data []byte, q url.Values) (*http.Response, []byte, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	if c.Datacenter != "" {
+		q.Set("dc", c.Datacenter)
+	}
+
+	u := url.URL{
+		Scheme:   c.Scheme,
+		Host:     c.Address,
+		Path:     "/v1/kv/" + key,
+		RawQuery: strings.Replace(q.Encode(), "raw=", "raw", 1),
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	return do(c.Client, req)
+}
+
+func isTrue(body []byte) bool {
+	return string(bytes.TrimSpace(body)) == "true"
+}
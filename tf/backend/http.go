@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// HTTP stores the state with the REST protocol of terraform's http
+// backend: GET and POST on address, LOCK and UNLOCK on the lock
+// address. The server answers a LOCK of a held lock with 423 or 409
+// and the holder's lock info.
+type HTTP struct {
+	Address       string
+	LockAddress   string
+	UnlockAddress string
+	LockMethod    string
+	UnlockMethod  string
+	Username      string
+	Password      string
+
+	Client *http.Client
+
+	lockID string
+}
+
+func newHTTP(config map[string]string) (Backend, error) {
+	if err := required(config, "address"); err != nil {
+		return nil, err
+	}
+
+	for _, k := range []string{"address", "lock_address", "unlock_address"} {
+		if v := config[k]; v != "" {
+			if _, err := url.Parse(v); err != nil {
+				return nil, fmt.Errorf("Invalid %s '%s': %s", k, v, err)
+			}
+		}
+	}
+
+	lockAddress := withDefault(config, "lock_address", config["address"])
+
+	return &HTTP{
+		Address:       config["address"],
+		LockAddress:   lockAddress,
+		UnlockAddress: withDefault(config, "unlock_address", lockAddress),
+		LockMethod:    withDefault(config, "lock_method", "LOCK"),
+		UnlockMethod:  withDefault(config, "unlock_method", "UNLOCK"),
+		Username:      config["username"],
+		Password:      config["password"],
+		Client:        http.DefaultClient,
+	}, nil
+}
+
+func (h *HTTP) String() string {
+	return h.Address
+}
+
+func (h *HTTP) Read() ([]byte, error) {
+	rsp, body, err := h.do("GET", h.Address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound, http.StatusNoContent:
+		return nil, nil
+	}
+
+	return nil, statusError("GET", h.Address, rsp, body)
+}
+
+func (h *HTTP) Write(data []byte) error {
+	address := h.Address
+	if h.lockID != "" {
+		u, err := url.Parse(address)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		q.Set("ID", h.lockID)
+		u.RawQuery = q.Encode()
+		address = u.String()
+	}
+
+	rsp, body, err := h.do("POST", address, data)
+	if err != nil {
+		return err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+
+	return statusError("POST", h.Address, rsp, body)
+}
+
+func (h *HTTP) Lock(info *LockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	rsp, body, err := h.do(h.LockMethod, h.LockAddress, data)
+	if err != nil {
+		return err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		h.lockID = info.ID
+		return nil
+	case http.StatusLocked, http.StatusConflict:
+		held := new(LockInfo)
+		if err := json.Unmarshal(body, held); err != nil || held.ID == "" {
+			held = nil
+		}
+		return &LockedError{Info: held}
+	}
+
+	return statusError(h.LockMethod, h.LockAddress, rsp, body)
+}
+
+func (h *HTTP) Unlock(id string) error {
+	data, err := json.Marshal(&LockInfo{ID: id})
+	if err != nil {
+		return err
+	}
+
+	rsp, body, err := h.do(h.UnlockMethod, h.UnlockAddress, data)
+	if err != nil {
+		return err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return statusError(h.UnlockMethod, h.UnlockAddress, rsp, body)
+	}
+
+	if id == h.lockID {
+		h.lockID = ""
+	}
+
+	return nil
+}
+
+// LockInfo always fails. The protocol has no way to read a lock
+// without taking it.
+func (h *HTTP) LockInfo() (*LockInfo, error) {
+	return nil, ErrNoLockInfo
+}
+
+func (h *HTTP) do(method, address string, data []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, address, bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+		sum := md5.Sum(data)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	if h.Username != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+
+	return do(h.Client, req)
+}
+
+// do sends a request and reads the whole response
+func do(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rsp.Body.Close()
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rsp, body, nil
+}
+
+func statusError(method, address string, rsp *http.Response, body []byte) error {
+	msg := string(bytes.TrimSpace(body))
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+
+	return fmt.Errorf("%s %s: %s %s", method, address, rsp.Status, msg)
+}
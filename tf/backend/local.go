@@ -0,0 +1,283 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The previous state is kept in <state>.backup. With a history of N the
+// last N states are also kept in the <state>.history directory.
+const (
+	BackupSuffix  = ".backup"
+	HistorySuffix = ".history"
+	LockSuffix    = ".lock"
+
+	historyTimeFormat = "20060102T150405.000000000Z"
+)
+
+// Local stores the state in a file. Writes never leave a partly
+// written file behind and the previous version is backed up.
+type Local struct {
+	Path    string
+	History int
+}
+
+func newLocal(config map[string]string) (Backend, error) {
+	if err := required(config, "path"); err != nil {
+		return nil, err
+	}
+
+	history := 0
+	if h := config["history"]; h != "" {
+		n, err := strconv.Atoi(h)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid history '%s'", h)
+		}
+		history = n
+	}
+
+	return &Local{Path: config["path"], History: history}, nil
+}
+
+func (l *Local) String() string {
+	return l.Path
+}
+
+func (l *Local) Read() ([]byte, error) {
+	data, err := ioutil.ReadFile(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+func (l *Local) Write(data []byte) error {
+	if err := l.backup(data); err != nil {
+		return err
+	}
+
+	return writeAtomic(l.Path, data)
+}
+
+// Replace writes the state without keeping the one it replaces as a
+// version. It is for the same state in a new encoding, not for a change.
+func (l *Local) Replace(data []byte) error {
+	return writeAtomic(l.Path, data)
+}
+
+// backup copies the current state to the backup and the history unless
+// it is about to be replaced by identical data
+func (l *Local) backup(data []byte) error {
+	old, err := l.Read()
+	if err != nil {
+		return err
+	}
+
+	if old == nil || bytes.Equal(old, data) {
+		return nil
+	}
+
+	if err := writeAtomic(l.Path+BackupSuffix, old); err != nil {
+		return err
+	}
+
+	if l.History <= 0 {
+		return nil
+	}
+
+	dir := l.Path + HistorySuffix
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	name := filepath.Join(dir, filepath.Base(l.Path)+"."+time.Now().UTC().Format(historyTimeFormat))
+	if err := writeAtomic(name, old); err != nil {
+		return err
+	}
+
+	return l.pruneHistory()
+}
+
+// historyFiles returns the states in the history, newest first
+func (l *Local) historyFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(l.Path+HistorySuffix, filepath.Base(l.Path)+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The timestamps sort in time order
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	return files, nil
+}
+
+func (l *Local) pruneHistory() error {
+	files, err := l.historyFiles()
+	if err != nil {
+		return err
+	}
+
+	for i := l.History; i < len(files); i++ {
+		if err := os.Remove(files[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Versions returns the backup and the history files, newest first
+func (l *Local) Versions() ([]string, error) {
+	versions := []string{}
+
+	if _, err := os.Stat(l.Path + BackupSuffix); err == nil {
+		versions = append(versions, l.Path+BackupSuffix)
+	}
+
+	files, err := l.historyFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(versions, files...), nil
+}
+
+// RewriteVersions replaces every version with what f returns for it.
+// A version is left as it is if f returns nil.
+func (l *Local) RewriteVersions(f func(data []byte) ([]byte, error)) error {
+	versions, err := l.Versions()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		data, err := ioutil.ReadFile(v)
+		if err != nil {
+			return err
+		}
+
+		data, err = f(data)
+		if err != nil {
+			return err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		if err := writeAtomic(v, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Local) lockPath() string {
+	return l.Path + LockSuffix
+}
+
+// Lock writes the lock to a temporary file and links it into place, so
+// the lock file never exists without its content
+func (l *Local) Lock(info *LockInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(l.lockPath()), "."+filepath.Base(l.lockPath())+".")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Link(tmp, l.lockPath())
+	if os.IsExist(err) {
+		held, err := l.LockInfo()
+		if err != nil {
+			return err
+		}
+		if held == nil {
+			// Released in the meantime
+			return l.Lock(info)
+		}
+		return &LockedError{Info: held}
+	}
+
+	return err
+}
+
+func (l *Local) Unlock(id string) error {
+	info, err := l.LockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+	if info.ID != id {
+		return mismatchError(id, info)
+	}
+
+	return os.Remove(l.lockPath())
+}
+
+func (l *Local) LockInfo() (*LockInfo, error) {
+	data, err := ioutil.ReadFile(l.lockPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLockInfo(data), nil
+}
+
+// writeAtomic writes data to a temporary file in the same directory and
+// renames it over path
+func writeAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+
+	return nil
+}
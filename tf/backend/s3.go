@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+
+	defaultKey = "pony.state"
+)
+
+// S3 stores the state as an object in an S3 bucket. Google Cloud
+// Storage is used through its S3 compatible API with HMAC keys. Both
+// are signed with the AWS signature version 4. The lock is the object
+// <key>.lock, created only if it does not exist.
+type S3 struct {
+	Bucket    string
+	Key       string
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+
+	Client *http.Client
+
+	// gcs adds the header GCS uses for create-only writes
+	gcs bool
+}
+
+func newS3(config map[string]string) (Backend, error) {
+	region := withDefault(config, "region", os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return s3Backend(config, region, fmt.Sprintf("https://s3.%s.amazonaws.com", region), false)
+}
+
+func newGCS(config map[string]string) (Backend, error) {
+	return s3Backend(config, withDefault(config, "region", "auto"), gcsEndpoint, true)
+}
+
+func s3Backend(config map[string]string, region, endpoint string, gcs bool) (Backend, error) {
+	if err := required(config, "bucket"); err != nil {
+		return nil, err
+	}
+
+	endpoint = withDefault(config, "endpoint", endpoint)
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("Invalid endpoint '%s': %s", endpoint, err)
+	}
+
+	return &S3{
+		Bucket:    config["bucket"],
+		Key:       strings.TrimPrefix(withDefault(config, "key", defaultKey), "/"),
+		Region:    region,
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		AccessKey: withDefault(config, "access_key", os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey: withDefault(config, "secret_key", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		Client:    http.DefaultClient,
+		gcs:       gcs,
+	}, nil
+}
+
+func (s *S3) String() string {
+	scheme := "s3"
+	if s.gcs {
+		scheme = "gs"
+	}
+
+	return fmt.Sprintf("%s://%s/%s", scheme, s.Bucket, s.Key)
+}
+
+func (s *S3) lockKey() string {
+	return s.Key + LockSuffix
+}
+
+func (s *S3) Read() ([]byte, error) {
+	return s.get(s.Key)
+}
+
+func (s *S3) Write(data []byte) error {
+	rsp, body, err := s.do("PUT", s.Key, data, nil)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return statusError("PUT", s.String(), rsp, body)
+	}
+
+	return nil
+}
+
+func (s *S3) Lock(info *LockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"If-None-Match": {"*"}}
+	if s.gcs {
+		header.Set("X-Goog-If-Generation-Match", "0")
+	}
+
+	rsp, body, err := s.do("PUT", s.lockKey(), data, header)
+	if err != nil {
+		return err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		held, err := s.LockInfo()
+		if err != nil {
+			return err
+		}
+		return &LockedError{Info: held}
+	}
+
+	return statusError("PUT", s.lockKey(), rsp, body)
+}
+
+func (s *S3) Unlock(id string) error {
+	info, err := s.LockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+	if info.ID != id {
+		return mismatchError(id, info)
+	}
+
+	rsp, body, err := s.do("DELETE", s.lockKey(), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+
+	return statusError("DELETE", s.lockKey(), rsp, body)
+}
+
+func (s *S3) LockInfo() (*LockInfo, error) {
+	data, err := s.get(s.lockKey())
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return parseLockInfo(data), nil
+}
+
+func (s *S3) get(key string) ([]byte, error) {
+	rsp, body, err := s.do("GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+
+	return nil, statusError("GET", key, rsp, body)
+}
+
+func (s *S3) do(method, key string, data []byte, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, s.Endpoint+"/"+s.Bucket+"/"+key, bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if s.AccessKey != "" {
+		signer := v4.NewSigner(credentials.NewStaticCredentials(s.AccessKey, s.SecretKey, ""))
+		if _, err := signer.Sign(req, bytes.NewReader(data), "s3", s.Region, time.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return do(s.Client, req)
+}
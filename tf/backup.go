@@ -0,0 +1,133 @@
+package tf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asteris-llc/pony/tf/backend"
+)
+
+func (tf *Tf) SetStateHistory(n int) {
+	tf.stateHistory = n
+}
+
+// RestoreState replaces a state with its backup or one of its history
+// files. from is "backup", a history file name or empty to choose from
+// a list. The replaced state becomes the new backup.
+func (tf *Tf) RestoreState(from string, yes bool) error {
+	l, ok := tf.stateBackend().(*backend.Local)
+	if !ok {
+		return fmt.Errorf("Backups are only kept by the local backend. Restore %s with the tools of its backend", tf.stateBackend())
+	}
+	statePath := l.Path
+
+	versions, err := l.Versions()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("No backups of %s", statePath)
+	}
+
+	var chosen string
+	switch from {
+	case "":
+		labels := make([]string, len(versions))
+		for i, v := range versions {
+			labels[i] = tf.describeState(v)
+		}
+
+		label, err := tf.cli.Select("state to restore", labels)
+		if err != nil {
+			return err
+		}
+		chosen = versions[indexOf(labels, label)]
+	case "backup":
+		chosen = versions[0]
+		if !strings.HasSuffix(chosen, backend.BackupSuffix) {
+			return fmt.Errorf("No backup of %s", statePath)
+		}
+	default:
+		for _, v := range versions {
+			if v == from || filepath.Base(v) == from {
+				chosen = v
+			}
+		}
+		if chosen == "" {
+			return fmt.Errorf("'%s' is not a backup of %s", from, statePath)
+		}
+	}
+
+	data, err := ioutil.ReadFile(chosen)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := tf.openState(data)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := parseStateFile(plaintext); err != nil {
+		return fmt.Errorf("%s is not a valid state: %s", chosen, err)
+	}
+
+	if !yes && !tf.cli.AskYesNo(fmt.Sprintf("Replace %s with %s? (y/N)", statePath, chosen), "n") {
+		return fmt.Errorf("Aborted. Nothing was changed")
+	}
+
+	if err := tf.lock("restore"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
+	tf.startWrite()
+	if err := l.Write(data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s from %s\n", statePath, chosen)
+
+	return nil
+}
+
+// describeState labels a state file with its age and size
+func (tf *Tf) describeState(path string) string {
+	label := filepath.Base(path)
+
+	if fi, err := os.Stat(path); err == nil {
+		label = fmt.Sprintf("%s (saved %s)", label, fi.ModTime().Format(time.RFC1123))
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		data, err = tf.openState(data)
+	}
+	if err != nil {
+		return label
+	}
+
+	if s, _, err := parseStateFile(data); err == nil && s != nil {
+		count := 0
+		for _, m := range s.Modules {
+			count += len(m.Resources)
+		}
+		label = fmt.Sprintf("%s, serial %d, %d resources", label, s.Serial, count)
+	}
+
+	return label
+}
+
+func indexOf(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+
+	return -1
+}
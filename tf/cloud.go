@@ -4,25 +4,67 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/asteris-llc/pony/cli"
+
 	"github.com/hashicorp/go-getter"
 	"github.com/hashicorp/terraform/config/module"
 )
 
 func (tf *Tf) SelectCloud() error {
+	if !tf.cli.Interactive() {
+		name, ok := tf.cli.Answer(cli.ProviderSection, "cloud")
+		if !ok {
+			tf.cli.MissingAnswer(cli.ProviderSection, "cloud")
+			return tf.cli.CheckAnswers()
+		}
+
+		if found := tf.cloudList.GetProvider(name); found != nil {
+			tf.cloudProvider = found
+			tf.cli.Record(cli.ProviderSection, "cloud", name)
+			return nil
+		}
+
+		return fmt.Errorf("Invalid cloud provider in answers: %s", name)
+	}
+
 	prompt := fmt.Sprintf("Select a cloud provider(%s)", strings.Join(tf.cloudList.Keys(), ","))
 	for {
 		rsp, err := tf.cli.AskRequired(prompt)
+		if cli.IsBack(err) {
+			// Nothing before the first question
+			continue
+		}
 		if err != nil {
 			return err
 		}
 
 		if found := tf.cloudList.GetProvider(rsp); found != nil {
 			tf.cloudProvider = found
+			tf.cli.Record(cli.ProviderSection, "cloud", rsp)
 			return nil
 		}
 	}
 }
 
+// selectStateCloud uses the cloud recorded in the "cloud" output of the
+// state. It asks if there is none or it is unknown.
+func (tf *Tf) selectStateCloud() error {
+	if root := tf.state.RootModule(); root != nil {
+		if cloudVar, ok := root.Outputs["cloud"]; ok {
+			// Verify that the cloud output is a string
+			if cloudName, ok := cloudVar.Value.(string); ok {
+				if cp := tf.cloudList.GetProvider(cloudName); cp != nil {
+					tf.cloudProvider = cp
+					tf.cli.Record(cli.ProviderSection, "cloud", cloudName)
+					return nil
+				}
+			}
+		}
+	}
+
+	return tf.SelectCloud()
+}
+
 func (tf *Tf) LoadCloud() error {
 	c, err := tf.loadInternal()
 	if err != nil {
@@ -10,7 +10,9 @@ import (
 
 type CloudProvider interface {
 	Root() []byte
-	GetProviderVars() (map[string]string, error)
+	// GetProviderVars returns the provider configuration. Values in
+	// the preset map have already been set and must not be asked for.
+	GetProviderVars(preset map[string]string) (map[string]string, error)
 	GetConfig(string) ([]byte, error)
 }
 
@@ -52,47 +52,107 @@ func (g *Google) GetConfig(mod string) ([]byte, error) {
 	}
 }
 
-func (g *Google) GetProviderVars() (map[string]string, error) {
+func (g *Google) GetProviderVars(preset map[string]string) (map[string]string, error) {
+	if !g.cli.Interactive() {
+		return g.answeredProviderVars(preset), nil
+	}
+
 	rval := make(map[string]string)
+	for k, v := range preset {
+		rval[k] = v
+	}
+
+	// Everything is preset. No need to talk to google
+	if hasAll(rval, "credentials", "project", "region", "zones") {
+		return rval, nil
+	}
 
 	// Get credentials file
-	g.cli.Println("\nPath to the JSON file for GCE credentials")
-	rsp, err := g.cli.AskRequiredWithDefault("Enter a value for credentials", "account.json")
-	if err != nil {
-		return nil, err
+	if _, ok := rval["credentials"]; !ok {
+		g.cli.Println("\nPath to the JSON file for GCE credentials")
+		rsp, err := g.cli.AskRequiredWithDefault("Enter a value for credentials", "account.json")
+		if err != nil {
+			return nil, err
+		}
+		rval["credentials"] = rsp
 	}
-	rval["credentials"] = rsp
 
 	// Authenticate to google
-	if err := g.readCredentials(rsp); err != nil {
+	if err := g.readCredentials(rval["credentials"]); err != nil {
 		return nil, err
 	}
 
-	project, err := g.getProject()
-	if err != nil {
-		return nil, err
+	if _, ok := rval["project"]; !ok {
+		project, err := g.getProject()
+		if err != nil {
+			return nil, err
+		}
+		rval["project"] = project
 	}
-	rval["project"] = project
 
 	// Select region
-	region, err := g.getRegion(project)
-	if err != nil {
-		return nil, err
+	if _, ok := rval["region"]; !ok {
+		region, err := g.getRegion(rval["project"])
+		if err != nil {
+			return nil, err
+		}
+		rval["region"] = region
+		fmt.Printf("Region: %s", region)
 	}
-	rval["region"] = region
-	fmt.Printf("Region: %s", region)
 
 	// Select zones
-	zones, err := g.getZones(project, region)
-	if err != nil {
-		return nil, err
+	if _, ok := rval["zones"]; !ok {
+		zones, err := g.getZones(rval["project"], rval["region"])
+		if err != nil {
+			return nil, err
+		}
+		rval["zones"] = zones
+		fmt.Printf("Zones: %s", zones)
 	}
-	rval["zones"] = zones
-	fmt.Printf("Zones: %s", zones)
 
 	return rval, nil
 }
 
+func hasAll(m map[string]string, keys ...string) bool {
+	for _, k := range keys {
+		if _, ok := m[k]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// answeredProviderVars reads the provider variables from the answers
+// file. Nothing is validated against the GCE API in non-interactive
+// mode; terraform reports bad values when it plans.
+func (g *Google) answeredProviderVars(preset map[string]string) map[string]string {
+	rval := make(map[string]string)
+	for k, v := range preset {
+		rval[k] = v
+	}
+
+	defaults := map[string]string{
+		"credentials": "account.json",
+	}
+
+	for _, name := range []string{"credentials", "project", "region", "zones"} {
+		if _, ok := rval[name]; ok {
+			continue
+		}
+
+		if v, ok := g.cli.Answer(cli.ProviderSection, name); ok {
+			rval[name] = v
+		} else if def, ok := defaults[name]; ok {
+			rval[name] = def
+		} else {
+			g.cli.MissingAnswer(cli.ProviderSection, name)
+		}
+	}
+
+	return rval
+}
+
 func (g *Google) readCredentials(path string) error {
 	clientScopes := []string{
 		"https://www.googleapis.com/auth/compute",
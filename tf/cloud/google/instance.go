@@ -14,6 +14,17 @@ variable "meta_ignored_variables" {
 		"network_name"
 	]
 }
+variable "meta_validation" {
+	type = "map"
+	default = {
+		count = "range:1-"
+		volume_size = "range:10-"
+		volume_type = "oneof:pd-standard,pd-ssd"
+		data_volume_size = "range:10-"
+		data_volume_type = "oneof:pd-standard,pd-ssd"
+		short_name = "gce-name"
+	}
+}
 
 variable "count" {}
 variable "machine_type" {default = "n1-standard-1"}
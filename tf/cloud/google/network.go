@@ -4,6 +4,14 @@ var network_module = `
 variable "network_ipv4" {default = "10.0.0.0/16"}
 variable "short_name" {default = "mantl"}
 
+variable "meta_validation" {
+	type = "map"
+	default = {
+		network_ipv4 = "cidr"
+		short_name = "gce-name"
+	}
+}
+
 # Network
 resource "google_compute_network" "mantl-network" {
   name = "${var.short_name}-network"
@@ -62,6 +62,14 @@ variable "meta_required_variables" {
 	]
 }
 
+variable "meta_validation" {
+	type = "map"
+
+	default = {
+		short_name = "gce-name"
+	}
+}
+
 variable "meta_provider_variables" {
 	type = "list"
 
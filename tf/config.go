@@ -0,0 +1,164 @@
+package tf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/asteris-llc/pony/cli"
+	"github.com/asteris-llc/pony/tf/cloud"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// savedConfig is everything needed to rebuild the module tree of a
+// cluster. It is stored in the state so later commands work from any
+// machine with the state file and never depend on the module sources
+// of the running pony version.
+type savedConfig struct {
+	Cloud string `json:"cloud"`
+
+	// Rendered root module and builtin modules by name
+	Root    string            `json:"root"`
+	Modules map[string]string `json:"modules"`
+
+	// Resolved values in the answers file layout
+	Variables map[string]map[string]interface{} `json:"variables"`
+
+	// Sensitive values, encrypted with a passphrase
+	Sensitive []byte `json:"sensitive,omitempty"`
+}
+
+// savedCloud serves the module sources of a saved configuration
+type savedCloud struct {
+	cloud.CloudProvider
+
+	config *savedConfig
+}
+
+func (c *savedCloud) Root() []byte {
+	return []byte(c.config.Root)
+}
+
+func (c *savedCloud) GetConfig(mod string) ([]byte, error) {
+	if m, ok := c.config.Modules[mod]; ok {
+		return []byte(m), nil
+	}
+
+	return nil, fmt.Errorf("Module %s not in the saved configuration", mod)
+}
+
+// updateConfig saves the current module sources and resolved values to
+// be written with the state
+func (tf *Tf) updateConfig() error {
+	r := tf.cli.Recorder()
+
+	name, _ := r.GetString(cli.ProviderSection, "cloud")
+
+	c := &savedConfig{
+		Cloud:     name,
+		Root:      string(tf.cloudProvider.Root()),
+		Modules:   tf.modules,
+		Variables: r.Values(false),
+	}
+
+	if sensitive := r.Values(true); len(sensitive) > 0 {
+		data, err := tf.encryptSensitive(sensitive)
+		if err != nil {
+			return err
+		}
+		c.Sensitive = data
+	}
+
+	tf.config = c
+
+	return nil
+}
+
+// encryptSensitive encrypts the sensitive values with the passphrase
+// they were read with. A new passphrase is asked for twice.
+func (tf *Tf) encryptSensitive(values map[string]map[string]interface{}) ([]byte, error) {
+	pass := tf.sensitivePass
+	if pass == "" {
+		if !hasPassphrase() {
+			if !tf.cli.Interactive() {
+				log.Warnf("Sensitive values are not saved in the state. Set %s to save them", PassphraseEnv)
+				return nil, nil
+			}
+			if !tf.cli.AskYesNo("Save the sensitive values in the state encrypted with a passphrase? (y/N)", "n") {
+				return nil, nil
+			}
+		}
+
+		var err error
+		pass, err = tf.newPassphrase(PassphraseEnv, "the sensitive values")
+		if err != nil {
+			return nil, err
+		}
+		tf.sensitivePass = pass
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypt(pass, data)
+}
+
+// useConfig rebuilds a cluster from its saved configuration. The module
+// sources are the saved ones and every question is answered with the
+// saved values.
+func (tf *Tf) useConfig(c *savedConfig) error {
+	provider := tf.cloudList.GetProvider(c.Cloud)
+	if provider == nil {
+		return fmt.Errorf("Unknown cloud provider '%s' in the saved configuration", c.Cloud)
+	}
+
+	a := cli.AnswersFromValues(c.Variables)
+
+	if len(c.Sensitive) > 0 {
+		if err := tf.decryptSensitive(a, c.Sensitive); err != nil {
+			return err
+		}
+	}
+
+	tf.cloudProvider = &savedCloud{CloudProvider: provider, config: c}
+	tf.cli.Record(cli.ProviderSection, "cloud", c.Cloud)
+	tf.config = c
+
+	tf.SetAnswers(a)
+
+	return nil
+}
+
+// decryptSensitive adds the saved sensitive values to the answers.
+// Without a passphrase they are reported as missing answers.
+func (tf *Tf) decryptSensitive(a *cli.Answers, data []byte) error {
+	pass, err := tf.passphrase("Passphrase for the sensitive values")
+	if err != nil {
+		return err
+	}
+	if pass == "" {
+		return nil
+	}
+
+	plaintext, err := decrypt(pass, data)
+	if err != nil {
+		return err
+	}
+	tf.sensitivePass = pass
+
+	values := make(map[string]map[string]interface{})
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return err
+	}
+
+	for section, names := range values {
+		for name, value := range names {
+			a.Set(section, name, value)
+			a.Sensitive(section, name)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/asteris-llc/pony/cli"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,28 +17,80 @@ var create_metaHandlers = []metaHandler{
 }
 
 func (tf *Tf) Create() error {
-	if err := tf.LoadCloud(); err != nil {
+	if err := tf.collect(); err != nil {
 		return err
 	}
 
-	if err := tf.ReadVariables(create_metaHandlers); err != nil {
+	if err := tf.Review(); err != nil {
+		return err
+	}
+
+	if err := tf.writeRecord(); err != nil {
+		return err
+	}
+
+	if err := tf.lockState("create"); err != nil {
 		return err
 	}
+	defer tf.unlock()
 
 	if err := tf.Context(false); err != nil {
 		return err
 	}
 
-	//	tf.dumpVariables(tf.tree)
+	tf.dumpVariables(tf.tree)
 
 	if err := tf.Plan(); err != nil {
 		return err
 	}
 
-	if err := tf.Apply(); err != nil {
+	if err := tf.updateConfig(); err != nil {
+		return err
+	}
+
+	return tf.Apply()
+}
+
+// collect selects the cloud, loads its configuration and resolves
+// every variable. An existing cluster in the state is updated rather
+// than created again.
+func (tf *Tf) collect() error {
+	existing, err := tf.loadExisting()
+	if err != nil {
+		return err
+	}
+
+	if existing {
+		fmt.Printf("Updating the cluster in %s\n", tf.stateBackend())
+		if err := tf.selectStateCloud(); err != nil {
+			return err
+		}
+	} else {
+		if err := tf.SelectCloud(); err != nil {
+			return err
+		}
+	}
+
+	if err := tf.LoadCloud(); err != nil {
+		return err
+	}
+
+	if err := tf.ReadVariables(create_metaHandlers); err != nil {
+		return err
+	}
+
+	if err := tf.cli.CheckAnswers(); err != nil {
+		return err
+	}
+
+	if err := tf.checkEnvAnswers(); err != nil {
 		return err
 	}
 
+	if tf.showSources {
+		tf.PrintSources()
+	}
+
 	return nil
 }
 
@@ -48,9 +102,14 @@ func create_metaOptionalHandler(tf *Tf, vs *variables) error {
 		return nil
 	}
 
+	// In non-interactive mode askForValue only consults the answers
+	// file so it is always safe to call
 	ask := true
-	if !tf.cli.AskYesNo("Configure optional parameters? (y/N)", "n") {
-		ask = false
+	if tf.cli.Interactive() {
+		var err error
+		if ask, err = tf.askOptional(); err != nil {
+			return err
+		}
 	}
 
 	for _, v := range *vs {
@@ -60,15 +119,21 @@ func create_metaOptionalHandler(tf *Tf, vs *variables) error {
 		}
 
 		// Set global values
-		if globalValue := tf.globals.get(v.name); globalValue != nil {
+		if globalValue := tf.globals.get(v.name); globalValue != nil && !v.fixed {
 			log.Debugf("Setting global value for %s", v.name)
 			v.setValue(globalValue.v.Default)
+			tf.setSource(v, SourceGlobal)
 		}
 
 		if ask {
 			if err := askForValue(tf, vs, v.name); err != nil {
 				return err
 			}
+		} else if !v.fixed {
+			// Keep the values of an existing cluster
+			if err := tf.useExisting(v); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -85,19 +150,52 @@ func create_metaProviderHandler(tf *Tf, vs *variables) error {
 		return nil
 	}
 
-	providerVars, err := tf.cloudProvider.GetProviderVars()
+	// Overridden values are passed to the cloud provider so it does
+	// not ask for them. So are the values of an existing cluster.
+	preset := make(map[string]string)
+	presetSource := make(map[string]string)
+	for _, vname := range providerList {
+		pv := vs.get(vname)
+		if pv == nil {
+			return fmt.Errorf("Provider variable '%s' not defined in module", vname)
+		}
+		if pv.fixed {
+			preset[vname] = pv.getDefault()
+		} else if value, ok := tf.existingValue(pv); ok {
+			preset[vname] = fmt.Sprint(value)
+			presetSource[vname] = SourceState
+		}
+	}
+
+	rsp, err := tf.ask(cli.ProviderSection, func() (interface{}, error) {
+		return tf.cloudProvider.GetProviderVars(preset)
+	})
 	if err != nil {
 		return err
 	}
+	providerVars := rsp.(map[string]string)
+
+	source := SourcePrompt
+	if !tf.cli.Interactive() {
+		source = SourceAnswers
+	}
 
 	for _, vname := range providerList {
 		pv := vs.get(vname)
-		if pv == nil {
-			return fmt.Errorf("Provider variable '%s' not defined in module", vname)
+		if pv.fixed {
 			continue
 		}
 		if providerVar, ok := providerVars[vname]; ok {
 			pv.setValue(providerVar)
+			if s, ok := presetSource[vname]; ok {
+				tf.setSource(pv, s)
+			} else {
+				tf.setSource(pv, source)
+			}
+			tf.cli.Record(cli.ProviderSection, vname, providerVar)
+		} else if !tf.cli.Interactive() {
+			// Already recorded as a missing answer by the cloud provider
+			vs.delete(vname)
 		} else {
 			if err := askForValue(tf, vs, vname); err != nil {
 				return err
@@ -0,0 +1,101 @@
+package tf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	PassphraseEnv = "PONY_PASSPHRASE"
+
+	saltSize = 16
+	keySize  = 32
+
+	// scrypt parameters recommended for interactive use
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// encrypt seals plaintext with AES-256-GCM using a key derived from the
+// passphrase. The salt and nonce are stored in front of the ciphertext.
+func encrypt(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(salt, nonce...)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+func decrypt(passphrase string, data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("Encrypted data is too short")
+	}
+
+	gcm, err := newGCM(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("Encrypted data is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt. Wrong passphrase?")
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func hasPassphrase() bool {
+	return os.Getenv(PassphraseEnv) != ""
+}
+
+// passphrase reads the passphrase from PONY_PASSPHRASE or asks for it.
+// It is empty only when there is no terminal to ask on.
+func (tf *Tf) passphrase(prompt string) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	if !tf.cli.Interactive() {
+		return "", nil
+	}
+
+	return tf.cli.AskSecret(prompt, "")
+}
@@ -1,11 +1,10 @@
 package tf
 
 import (
-	"bytes"
 	"fmt"
-	"io/ioutil"
-
-	"github.com/hashicorp/terraform/terraform"
+	"os"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -14,45 +13,42 @@ var destroy_metaHandlers = []metaHandler{
 	destroy_metaDestroyHandler,
 }
 
-func (tf *Tf) Destroy(statePath string) error {
-	data, err := ioutil.ReadFile(statePath)
+// Destroy destroys the cluster in the state. With roles or nodes only
+// those instances and the disks attached to them are destroyed, after
+// the plan is shown and confirmed.
+func (tf *Tf) Destroy(roles, nodes []string, yes bool) error {
+	state, config, err := tf.readStateFile()
 	if err != nil {
 		return err
 	}
-
-	state, err := terraform.ReadState(bytes.NewReader(data))
-	if err != nil {
-		return err
+	if state == nil {
+		return fmt.Errorf("No state found at %s", tf.stateBackend())
 	}
 	tf.state = state
 
-	// Get the outputs of the root module
-	root := tf.state.RootModule()
+	targeted := len(roles) > 0 || len(nodes) > 0
 
-	// Check for a "cloud" output telling us what configuration
-	// to use
-	if cloudVar, ok := root.Outputs["cloud"]; !ok {
-		if err := tf.SelectCloud(); err != nil {
+	var selected []Node
+	if targeted {
+		selected, err = selectNodes(stateNodes(state), roles, nodes)
+		if err != nil {
 			return err
 		}
-	} else {
-		// Verify that the cloud output is a string
-		switch cloudVar.Value.(type) {
-		case string:
-			cloudName := root.Outputs["cloud"].Value.(string)
-			if cp := tf.cloudList.GetProvider(cloudName); cp != nil {
-				tf.cloudProvider = cp
-				break
-			}
 
-			// Unknown cloud. Ask
-			if err := tf.SelectCloud(); err != nil {
-				return err
-			}
-		default:
-			if err := tf.SelectCloud(); err != nil {
-				return err
-			}
+		for _, n := range selected {
+			tf.targets = append(tf.targets, n.targets()...)
+		}
+	}
+
+	// The saved configuration rebuilds the tree the cluster was created
+	// with and answers every question
+	if config != nil {
+		if err := tf.useConfig(config); err != nil {
+			return err
+		}
+	} else {
+		if err := tf.selectStateCloud(); err != nil {
+			return err
 		}
 	}
 
@@ -62,10 +58,23 @@ func (tf *Tf) Destroy(statePath string) error {
 	}
 
 	// Read the configuration variables
-	if err := tf.ReadVariables(destroy_metaHandlers); err != nil {
+	if err := tf.ReadVariables(destroyHandlers(config)); err != nil {
 		return err
 	}
 
+	if err := tf.cli.CheckAnswers(); err != nil {
+		return err
+	}
+
+	if tf.showSources {
+		tf.PrintSources()
+	}
+
+	if err := tf.lockState("destroy"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
 	if err := tf.Context(true); err != nil {
 		return err
 	}
@@ -74,13 +83,122 @@ func (tf *Tf) Destroy(statePath string) error {
 		return err
 	}
 
+	if targeted {
+		if err := tf.confirmDestroy(selected, yes); err != nil {
+			return err
+		}
+	}
+
 	if err := tf.Apply(); err != nil {
 		return err
 	}
 
+	if targeted {
+		log.Warnf("The saved configuration still counts the destroyed nodes. Creating or updating the cluster brings them back. Use 'pony scale' to remove nodes for good")
+	}
+
+	return nil
+}
+
+// confirmDestroy shows the nodes and the plan of a targeted destroy and
+// asks before anything is destroyed
+func (tf *Tf) confirmDestroy(selected []Node, yes bool) error {
+	fmt.Println("\nNodes to destroy:")
+	for _, n := range selected {
+		disks := []string{}
+		for _, d := range n.Disks {
+			if d.address != "" {
+				disks = append(disks, d.Name)
+			}
+		}
+
+		if len(disks) == 0 {
+			fmt.Printf("  %s (%s)\n", n.Name, n.Role)
+		} else {
+			fmt.Printf("  %s (%s) with disks %s\n", n.Name, n.Role, strings.Join(disks, ", "))
+		}
+	}
+	fmt.Println()
+
+	tf.formatPlan(os.Stdout, tf.plan)
+
+	if tf.plan.Diff == nil || tf.plan.Diff.Empty() {
+		return fmt.Errorf("Nothing to destroy")
+	}
+
+	// The saved configuration answered every question. Confirm on the
+	// terminal.
+	tf.SetAnswers(nil)
+	if !yes && !tf.cli.AskYesNo(fmt.Sprintf("Destroy %d nodes? (y/N)", len(selected)), "n") {
+		return fmt.Errorf("Aborted. Nothing was changed")
+	}
+
 	return nil
 }
 
+// destroyHandlers resolves the variables of a destroy. A saved
+// configuration answers every variable like a create, so the tree is
+// rebuilt with the values the cluster was last created, updated or
+// scaled with. Without one only the destroy variables are read.
+func destroyHandlers(config *savedConfig) []metaHandler {
+	if config != nil {
+		return create_metaHandlers
+	}
+
+	return destroy_metaHandlers
+}
+
+// selectNodes returns the nodes with one of roles and the nodes named
+// in names, each once
+func selectNodes(all []Node, roles, names []string) ([]Node, error) {
+	rval := []Node{}
+	seen := make(map[string]bool)
+
+	add := func(n Node) {
+		if !seen[n.address] {
+			seen[n.address] = true
+			rval = append(rval, n)
+		}
+	}
+
+	for _, role := range roles {
+		found := false
+		for _, n := range all {
+			if n.Role == role {
+				add(n)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("No nodes with role '%s'. Roles: %s", role, strings.Join(nodeRoles(all), ", "))
+		}
+	}
+
+	for _, name := range names {
+		n, err := findNode(all, []string{name})
+		if err != nil {
+			return nil, err
+		}
+		add(n)
+	}
+
+	sort.Sort(byRole(rval))
+
+	return rval, nil
+}
+
+func nodeRoles(nodes []Node) []string {
+	roles := []string{}
+	for _, n := range nodes {
+		if indexOf(roles, n.Role) < 0 {
+			roles = append(roles, n.Role)
+		}
+	}
+
+	return roles
+}
+
 func destroy_metaDestroyHandler(tf *Tf, vs *variables) error {
 	destroyList, err := vs.getStringList(MetaDestroy)
 	if err != nil {
@@ -106,8 +224,10 @@ func destroy_metaDestroyHandler(tf *Tf, vs *variables) error {
 				continue
 			}
 
-			dvar := vs.get(vname)
-			dvar.setValue(outputVar.Value.(string))
+			if dvar := vs.get(vname); !dvar.fixed {
+				dvar.setValue(outputVar.Value.(string))
+				tf.setSource(dvar, SourceState)
+			}
 		}
 
 		if err := askForValue(tf, vs, vname); err != nil {
@@ -0,0 +1,49 @@
+package tf
+
+import (
+	"testing"
+
+	"github.com/asteris-llc/pony/cli"
+	"github.com/hashicorp/terraform/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testScaledModule returns the variables of a node module created with
+// 3 nodes
+func testScaledModule() (*variables, *variable) {
+	count := &variable{name: "count", module: "worker-nodes", v: &config.Variable{Name: "count", Default: "3"}}
+
+	vs := newVariables()
+	vs.set("count", count)
+	vs.set(MetaRequired, &variable{name: MetaRequired, module: "worker-nodes", v: &config.Variable{
+		Name:    MetaRequired,
+		Default: []interface{}{"count"},
+	}})
+
+	return vs, count
+}
+
+func TestDestroyHandlers_AfterScale(t *testing.T) {
+	assert := assert.New(t)
+
+	// The configuration saved by a scale from 3 to 5 nodes
+	saved := &savedConfig{Variables: map[string]map[string]interface{}{
+		"worker-nodes": {"count": "5"},
+	}}
+
+	tf := New()
+	tf.SetAnswers(cli.AnswersFromValues(saved.Variables))
+
+	vs, count := testScaledModule()
+	assert.NoError(tf.processModule(nil, vs, destroyHandlers(saved), ""))
+	assert.Equal("5", count.v.Default)
+	assert.Equal(SourceAnswers, tf.sources[count.key()])
+
+	// Without a saved configuration only the destroy variables are read
+	tf = New()
+	tf.SetAnswers(cli.AnswersFromValues(saved.Variables))
+
+	vs, count = testScaledModule()
+	assert.NoError(tf.processModule(nil, vs, destroyHandlers(nil), ""))
+	assert.Equal("3", count.v.Default)
+}
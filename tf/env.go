@@ -0,0 +1,370 @@
+package tf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/asteris-llc/pony/cli"
+	"github.com/asteris-llc/pony/tf/backend"
+
+	"github.com/hashicorp/terraform/terraform"
+	"gopkg.in/yaml.v2"
+)
+
+// Each environment keeps its state, its recorded answers and the cloud
+// and project it is pinned to in .pony/<env>/. The selected environment
+// is stored in .pony/environment.
+const (
+	EnvDir = ".pony"
+	EnvEnv = "PONY_ENV"
+
+	ShortNameVar = "short_name"
+	SourceEnvDir = "pony env"
+
+	envSelectFile  = "environment"
+	envInfoFile    = "env.yml"
+	envAnswersFile = "answers.yml"
+)
+
+// Environment names become the short_name of the cluster, which
+// prefixes cloud resource names
+var envNameRe = regexp.MustCompile(`^[a-z][a-z0-9-]{0,19}$`)
+
+// envInfo is the cloud and project an environment is pinned to. They
+// are recorded from the state outputs the first time they are seen.
+type envInfo struct {
+	Cloud   string `yaml:"cloud,omitempty"`
+	Project string `yaml:"project,omitempty"`
+}
+
+func envPath(name string, file string) string {
+	return filepath.Join(EnvDir, name, file)
+}
+
+func envExists(name string) bool {
+	fi, err := os.Stat(filepath.Join(EnvDir, name))
+	return err == nil && fi.IsDir()
+}
+
+// CurrentEnv returns the environment chosen with SelectEnv or "" if
+// there is none
+func CurrentEnv() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(EnvDir, envSelectFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetEnv makes every command use the state of an environment
+func (tf *Tf) SetEnv(name string) error {
+	if !envExists(name) {
+		return fmt.Errorf("Environment '%s' does not exist. Create it with 'pony env new %s'", name, name)
+	}
+
+	tf.env = name
+
+	return nil
+}
+
+// EnvStatePath is the default state file of the current environment
+func (tf *Tf) EnvStatePath() string {
+	return envPath(tf.env, StatePath)
+}
+
+// EnvAnswersPath is where the answers of the current environment are
+// recorded
+func (tf *Tf) EnvAnswersPath() string {
+	return envPath(tf.env, envAnswersFile)
+}
+
+// EnvAnswers reads the answers recorded in the current environment. It
+// returns nil if none were recorded yet.
+func (tf *Tf) EnvAnswers() (*cli.Answers, error) {
+	path := tf.EnvAnswersPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return cli.ReadAnswers(path)
+}
+
+// NewEnv creates an environment. cloud and project pin it up front;
+// otherwise they are pinned by its first state.
+func NewEnv(name, cloud, project string) error {
+	if !envNameRe.MatchString(name) {
+		return fmt.Errorf("Invalid environment name '%s'. Use up to 20 lower case letters, digits and dashes, starting with a letter", name)
+	}
+	if envExists(name) {
+		return fmt.Errorf("Environment '%s' already exists", name)
+	}
+
+	if err := os.MkdirAll(filepath.Join(EnvDir, name), 0700); err != nil {
+		return err
+	}
+
+	if err := writeEnvInfo(name, &envInfo{Cloud: cloud, Project: project}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created environment %s\n", name)
+
+	return nil
+}
+
+// SelectEnv makes an environment the default for later commands
+func SelectEnv(name string) error {
+	if !envExists(name) {
+		return fmt.Errorf("Environment '%s' does not exist", name)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(EnvDir, envSelectFile), []byte(name+"\n"), 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Switched to environment %s\n", name)
+
+	return nil
+}
+
+// ListEnvs prints the environments. The selected one is marked with *.
+func ListEnvs() error {
+	current, err := CurrentEnv()
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(EnvDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		fmt.Println("No environments. Create one with 'pony env new <name>'")
+		return nil
+	}
+
+	for _, name := range names {
+		mark := " "
+		if name == current {
+			mark = "*"
+		}
+
+		info, err := readEnvInfo(name)
+		if err != nil {
+			return err
+		}
+
+		pinned := []string{}
+		if info.Cloud != "" {
+			pinned = append(pinned, "cloud "+info.Cloud)
+		}
+		if info.Project != "" {
+			pinned = append(pinned, "project "+info.Project)
+		}
+
+		line := fmt.Sprintf("%s %-20s %s", mark, name, strings.Join(pinned, ", "))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	return nil
+}
+
+// DeleteEnv removes the environment chosen with SetEnv. Its cluster
+// must be destroyed first.
+func (tf *Tf) DeleteEnv(yes bool) error {
+	name := tf.env
+	if name == "" {
+		return fmt.Errorf("No environment to delete")
+	}
+
+	if err := tf.lock("delete environment"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
+	state, err := tf.readState()
+	if err != nil {
+		return err
+	}
+	if !stateEmpty(state) {
+		return fmt.Errorf("Environment '%s' still has a cluster in %s. Run 'pony destroy --env %s' first", name, tf.stateBackend(), name)
+	}
+
+	if !yes && !tf.cli.AskYesNo(fmt.Sprintf("Delete environment %s and its state history? (y/N)", name), "n") {
+		return fmt.Errorf("Aborted. Nothing was changed")
+	}
+
+	// Release the lock before its directory goes away
+	tf.unlock()
+
+	tf.startWrite()
+	if err := os.RemoveAll(filepath.Join(EnvDir, name)); err != nil {
+		return err
+	}
+
+	if current, err := CurrentEnv(); err == nil && current == name {
+		os.Remove(filepath.Join(EnvDir, envSelectFile))
+	}
+
+	fmt.Printf("Deleted environment %s\n", name)
+
+	if _, ok := tf.stateBackend().(*backend.Local); !ok && state != nil {
+		fmt.Printf("Its empty state is left in %s\n", tf.stateBackend())
+	}
+
+	return nil
+}
+
+func readEnvInfo(name string) (*envInfo, error) {
+	info := new(envInfo)
+
+	data, err := ioutil.ReadFile(envPath(name, envInfoFile))
+	if os.IsNotExist(err) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", envPath(name, envInfoFile), err)
+	}
+
+	return info, nil
+}
+
+func writeEnvInfo(name string, info *envInfo) error {
+	data, err := yaml.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(envPath(name, envInfoFile), data, 0600)
+}
+
+// stateEnvInfo returns the cloud and project outputs of a state
+func stateEnvInfo(s *terraform.State) *envInfo {
+	info := new(envInfo)
+	if s == nil || s.RootModule() == nil {
+		return info
+	}
+
+	outputs := s.RootModule().Outputs
+	if o, ok := outputs["cloud"]; ok {
+		info.Cloud, _ = o.Value.(string)
+	}
+	if o, ok := outputs["project"]; ok {
+		info.Project, _ = o.Value.(string)
+	}
+
+	return info
+}
+
+// checkEnv refuses a state whose cloud or project is not the one the
+// environment is pinned to, e.g. a prod state copied into dev
+func (tf *Tf) checkEnv(s *terraform.State) error {
+	return tf.compareEnv(stateEnvInfo(s), "its state is")
+}
+
+// checkEnvAnswers refuses to build a cluster in a cloud or project the
+// environment is not pinned to
+func (tf *Tf) checkEnvAnswers() error {
+	found := new(envInfo)
+
+	a := tf.cli.Recorder()
+	if v, ok := a.Get(cli.ProviderSection, "cloud"); ok {
+		found.Cloud = fmt.Sprint(v)
+	}
+	if v, ok := a.Get(cli.ProviderSection, "project"); ok {
+		found.Project = fmt.Sprint(v)
+	}
+
+	return tf.compareEnv(found, "the answers are")
+}
+
+func (tf *Tf) compareEnv(found *envInfo, what string) error {
+	if tf.env == "" {
+		return nil
+	}
+
+	pinned, err := readEnvInfo(tf.env)
+	if err != nil {
+		return err
+	}
+
+	if pinned.Cloud != "" && found.Cloud != "" && pinned.Cloud != found.Cloud {
+		return fmt.Errorf("Environment %s is pinned to cloud %s but %s for %s", tf.env, pinned.Cloud, what, found.Cloud)
+	}
+	if pinned.Project != "" && found.Project != "" && pinned.Project != found.Project {
+		return fmt.Errorf("Environment %s is pinned to project %s but %s for %s", tf.env, pinned.Project, what, found.Project)
+	}
+
+	return nil
+}
+
+// pinEnv records the cloud and project of the state in the
+// environment if they are not pinned yet
+func (tf *Tf) pinEnv() error {
+	if tf.env == "" {
+		return nil
+	}
+
+	pinned, err := readEnvInfo(tf.env)
+	if err != nil {
+		return err
+	}
+	found := stateEnvInfo(tf.state)
+
+	changed := false
+	if pinned.Cloud == "" && found.Cloud != "" {
+		pinned.Cloud = found.Cloud
+		changed = true
+	}
+	if pinned.Project == "" && found.Project != "" {
+		pinned.Project = found.Project
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return writeEnvInfo(tf.env, pinned)
+}
+
+// envDefaults makes the environment name the default short_name
+func (tf *Tf) envDefaults(vs *variables) {
+	if tf.env == "" {
+		return
+	}
+
+	v := vs.get(ShortNameVar)
+	if v == nil {
+		return
+	}
+
+	if _, ok := tf.sources[v.key()]; ok {
+		return
+	}
+
+	v.setValue(tf.env)
+	tf.setSource(v, SourceEnvDir)
+}
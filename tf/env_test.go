@@ -0,0 +1,48 @@
+package tf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvAnswers(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "pony")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(NewEnv("staging", "", ""))
+
+	tf := New()
+	assert.NoError(tf.SetEnv("staging"))
+
+	// Nothing recorded yet
+	a, err := tf.EnvAnswers()
+	assert.NoError(err)
+	assert.Nil(a)
+
+	answers := `{"root": {"short_name": "staging", "ssh_user": "centos"}}`
+	assert.NoError(ioutil.WriteFile(tf.EnvAnswersPath(), []byte(answers), 0600))
+
+	a, err = tf.EnvAnswers()
+	if assert.NoError(err) && assert.NotNil(a) {
+		user, ok := a.GetString("root", "ssh_user")
+		assert.True(ok)
+		assert.Equal("centos", user)
+	}
+}
@@ -0,0 +1,85 @@
+package tf
+
+import (
+	"fmt"
+
+	"github.com/asteris-llc/pony/cli"
+
+	"github.com/hashicorp/terraform/config/module"
+	log "github.com/sirupsen/logrus"
+)
+
+// Root outputs that hold the value of the provider variables. Clusters
+// created before the configuration was saved only have these.
+var stateProviderOutputs = []string{"credentials", "project", "region"}
+
+// loadExisting reads the cluster in the state file, if there is one.
+// Create then plans an update of that cluster. Every question defaults
+// to the value it was created with and the provider variables are
+// kept.
+func (tf *Tf) loadExisting() (bool, error) {
+	state, config, err := tf.readStateFile()
+	if err != nil {
+		return false, err
+	}
+	if stateEmpty(state) {
+		return false, nil
+	}
+
+	tf.state = state
+	tf.existing = cli.NewAnswers()
+
+	if config != nil {
+		tf.existing = cli.AnswersFromValues(config.Variables)
+	} else {
+		log.Warnf("No saved configuration in %s. Using module defaults", tf.stateBackend())
+	}
+
+	if root := state.RootModule(); root != nil {
+		for _, name := range stateProviderOutputs {
+			if _, ok := tf.existing.Get(cli.ProviderSection, name); ok {
+				continue
+			}
+			if o, ok := root.Outputs[name]; ok {
+				tf.existing.Set(cli.ProviderSection, name, o.Value)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// existingValue returns the value a variable had when the cluster in
+// the state was created
+func (tf *Tf) existingValue(v *variable) (interface{}, bool) {
+	if tf.existing == nil {
+		return nil, false
+	}
+
+	if value, ok := tf.existing.Get(v.module, v.name); ok {
+		return value, true
+	}
+
+	if v.module == module.RootName {
+		return tf.existing.Get(cli.ProviderSection, v.name)
+	}
+
+	return nil, false
+}
+
+// useExisting makes the existing value of a variable its default
+func (tf *Tf) useExisting(v *variable) error {
+	value, ok := tf.existingValue(v)
+	if !ok {
+		return nil
+	}
+
+	cv, err := v.convert(value)
+	if err != nil {
+		return fmt.Errorf("%s (from %s): %s", v.key(), SourceState, err)
+	}
+
+	tf.resolve(v, cv, SourceState)
+
+	return nil
+}
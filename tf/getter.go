@@ -17,6 +17,7 @@ func (tf *Tf) Get(dst string, u *url.URL) error {
 	if err != nil {
 		return err
 	}
+	tf.modules[u.Opaque] = string(mod)
 
 	// Hardcode the internal filename as main.tf
 	//
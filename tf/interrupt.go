@@ -0,0 +1,90 @@
+package tf
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleInterrupts catches Ctrl-C. At a prompt pony releases the state
+// lock itself, since deferred unlocks never run, cleans up and exits.
+// During apply the first Ctrl-C stops terraform once the resources in
+// flight are done so the partial state can be written. A second Ctrl-C
+// quits immediately.
+func (tf *Tf) HandleInterrupts() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range sigCh {
+			tf.interrupt()
+		}
+	}()
+}
+
+func (tf *Tf) interrupt() {
+	tf.l.Lock()
+	tf.interrupts++
+	stopCh, interrupts := tf.stopCh, tf.interrupts
+	tf.l.Unlock()
+
+	switch {
+	case stopCh == nil:
+		fmt.Fprintln(os.Stderr, "\n"+tf.interruptedMessage())
+		tf.Clean()
+		os.Exit(1)
+	case interrupts == 1:
+		fmt.Fprintln(os.Stderr, "\nInterrupt received. Waiting for running operations to finish")
+		fmt.Fprintln(os.Stderr, "Press Ctrl-C again to force quit")
+		close(stopCh)
+	default:
+		log.Warnf("Forced quit. Resources being changed are not recorded in %s and may need to be removed by hand", tf.stateOutBackend())
+		tf.Clean()
+		os.Exit(1)
+	}
+}
+
+// interruptedMessage tells whether anything was written before the
+// interrupt
+func (tf *Tf) interruptedMessage() string {
+	tf.l.Lock()
+	defer tf.l.Unlock()
+
+	if !tf.written {
+		return "Interrupted. Nothing was changed"
+	}
+
+	return "Interrupted. The state may already have been changed"
+}
+
+// startWrite records that the state is about to be changed. It is called
+// before the write so an interrupt during the write is not reported as
+// harmless.
+func (tf *Tf) startWrite() {
+	tf.l.Lock()
+	defer tf.l.Unlock()
+
+	tf.written = true
+}
+
+// startApply returns the channel closed by the first interrupt
+func (tf *Tf) startApply() <-chan struct{} {
+	tf.l.Lock()
+	defer tf.l.Unlock()
+
+	tf.interrupts = 0
+	tf.stopCh = make(chan struct{})
+	tf.written = true
+
+	return tf.stopCh
+}
+
+func (tf *Tf) stopApply() {
+	tf.l.Lock()
+	defer tf.l.Unlock()
+
+	tf.stopCh = nil
+}
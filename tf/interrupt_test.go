@@ -0,0 +1,27 @@
+package tf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterruptedMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	tf := New()
+	defer tf.Clean()
+
+	assert.Equal("Interrupted. Nothing was changed", tf.interruptedMessage())
+
+	// A prompt after the first write must not claim nothing changed
+	tf.startWrite()
+	assert.Equal("Interrupted. The state may already have been changed", tf.interruptedMessage())
+
+	tf = New()
+	defer tf.Clean()
+
+	tf.startApply()
+	tf.stopApply()
+	assert.Equal("Interrupted. The state may already have been changed", tf.interruptedMessage())
+}
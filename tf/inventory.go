@@ -0,0 +1,192 @@
+package tf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	InventoryINI  = "ini"
+	InventoryYAML = "yaml"
+)
+
+// inventory groups the nodes like mantl's terraform.py: role=<role> and
+// dc=<datacenter>
+type inventory struct {
+	groups   map[string][]string
+	hostvars map[string]map[string]string
+}
+
+func newInventory(nodes []Node) *inventory {
+	inv := &inventory{
+		groups:   make(map[string][]string),
+		hostvars: make(map[string]map[string]string),
+	}
+
+	for _, n := range nodes {
+		inv.hostvars[n.Name] = map[string]string{
+			"ansible_ssh_host": n.ExternalIP,
+			"ansible_ssh_user": n.SSHUser,
+			"private_ipv4":     n.InternalIP,
+			"public_ipv4":      n.ExternalIP,
+			"role":             n.Role,
+			"dc":               n.Datacenter,
+			"zone":             n.Zone,
+			"machine_type":     n.MachineType,
+		}
+
+		inv.add("role="+n.Role, n.Name)
+		if n.Datacenter != "" {
+			inv.add("dc="+n.Datacenter, n.Name)
+		}
+	}
+
+	return inv
+}
+
+func (inv *inventory) add(group, host string) {
+	inv.groups[group] = append(inv.groups[group], host)
+}
+
+func (inv *inventory) groupNames() []string {
+	names := make([]string, 0, len(inv.groups))
+	for g := range inv.groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (tf *Tf) loadInventory() (*inventory, error) {
+	state, err := tf.requireState()
+	if err != nil {
+		return nil, err
+	}
+
+	return newInventory(stateNodes(state)), nil
+}
+
+// Inventory prints a static ansible inventory in INI or YAML format
+func (tf *Tf) Inventory(format string) error {
+	inv, err := tf.loadInventory()
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(format) {
+	case InventoryINI:
+		inv.writeINI(os.Stdout)
+		return nil
+	case InventoryYAML:
+		return inv.writeYAML(os.Stdout)
+	}
+
+	return fmt.Errorf("Unknown inventory format '%s'. Use %s or %s", format, InventoryINI, InventoryYAML)
+}
+
+// InventoryList prints every group and host variable in the format
+// ansible expects from a dynamic inventory called with --list
+func (tf *Tf) InventoryList() error {
+	inv, err := tf.loadInventory()
+	if err != nil {
+		return err
+	}
+
+	list := make(map[string]interface{})
+	for g, hosts := range inv.groups {
+		list[g] = map[string][]string{"hosts": hosts}
+	}
+	list["_meta"] = map[string]interface{}{"hostvars": inv.hostvars}
+
+	return printJSON(list)
+}
+
+// InventoryHost prints the variables of one host for --host. Unknown
+// hosts have no variables.
+func (tf *Tf) InventoryHost(host string) error {
+	inv, err := tf.loadInventory()
+	if err != nil {
+		return err
+	}
+
+	vars, ok := inv.hostvars[host]
+	if !ok {
+		vars = map[string]string{}
+	}
+
+	return printJSON(vars)
+}
+
+func (inv *inventory) writeINI(w io.Writer) {
+	for _, g := range inv.groupNames() {
+		fmt.Fprintf(w, "[%s]\n", g)
+		for _, host := range inv.groups[g] {
+			fmt.Fprintf(w, "%s%s\n", host, formatHostVars(inv.hostvars[host]))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func (inv *inventory) writeYAML(w io.Writer) error {
+	children := make(map[string]interface{})
+	for g, hosts := range inv.groups {
+		h := make(map[string]interface{})
+		for _, host := range hosts {
+			h[host] = nil
+		}
+		children[g] = map[string]interface{}{"hosts": h}
+	}
+
+	hosts := make(map[string]interface{})
+	for host, vars := range inv.hostvars {
+		hosts[host] = vars
+	}
+
+	data, err := yaml.Marshal(map[string]interface{}{
+		"all": map[string]interface{}{
+			"hosts":    hosts,
+			"children": children,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func formatHostVars(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k, v := range vars {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	b := new(bytes.Buffer)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s=%s", k, vars[k])
+	}
+
+	return b.String()
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+	return nil
+}
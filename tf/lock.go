@@ -0,0 +1,114 @@
+package tf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asteris-llc/pony/tf/backend"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const lockRetryInterval = time.Second
+
+func (tf *Tf) SetLockTimeout(d time.Duration) {
+	tf.lockTimeout = d
+}
+
+// lock takes the state lock, retrying until the lock timeout
+func (tf *Tf) lock(operation string) error {
+	info, err := backend.NewLockInfo(operation)
+	if err != nil {
+		return err
+	}
+
+	b := tf.stateBackend()
+	deadline := time.Now().Add(tf.lockTimeout)
+
+	for {
+		err := b.Lock(info)
+		if err == nil {
+			break
+		}
+		if !backend.IsLocked(err) || time.Now().After(deadline) {
+			return err
+		}
+
+		log.Infof("Waiting for the state lock: %s", err)
+		time.Sleep(lockRetryInterval)
+	}
+
+	tf.l.Lock()
+	tf.lockID = info.ID
+	tf.l.Unlock()
+
+	return nil
+}
+
+// lockState takes the state lock and makes sure the state was not
+// changed since it was read
+func (tf *Tf) lockState(operation string) error {
+	if err := tf.lock(operation); err != nil {
+		return err
+	}
+
+	current, err := tf.readState()
+	if err != nil {
+		tf.unlock()
+		return err
+	}
+
+	if !(stateEmpty(tf.state) && stateEmpty(current)) && (stateEmpty(tf.state) || !tf.state.Equal(current)) {
+		tf.unlock()
+		return fmt.Errorf("%s was changed by another run. Start again", tf.stateBackend())
+	}
+
+	return nil
+}
+
+// unlock releases the state lock if it is held. It is safe to call more
+// than once.
+func (tf *Tf) unlock() {
+	tf.l.Lock()
+	id := tf.lockID
+	tf.lockID = ""
+	tf.l.Unlock()
+
+	if id == "" {
+		return
+	}
+
+	if err := tf.stateBackend().Unlock(id); err != nil {
+		log.Errorf("Error releasing the state lock: %s", err)
+	}
+}
+
+// ForceUnlock removes a stale lock. The ID must match the lock so a
+// lock taken in the meantime is never removed.
+func (tf *Tf) ForceUnlock(id string) error {
+	b := tf.stateBackend()
+
+	info, err := b.LockInfo()
+	if err == backend.ErrNoLockInfo {
+		if err := b.Unlock(id); err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed lock %s\n", id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("%s is not locked", b)
+	}
+
+	if err := b.Unlock(id); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed the lock held by %s\n", info)
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package tf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const (
+	instanceResource = "google_compute_instance"
+	diskResource     = "google_compute_disk"
+)
+
+// Node is a cluster instance read from the state
+type Node struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Datacenter  string `json:"datacenter"`
+	Module      string `json:"module"`
+	Zone        string `json:"zone"`
+	MachineType string `json:"machine_type"`
+	InternalIP  string `json:"internal_ip"`
+	ExternalIP  string `json:"external_ip"`
+	SSHUser     string `json:"ssh_user"`
+	Disks       []Disk `json:"disks"`
+
+	// address is the resource address used to target the instance
+	address string
+}
+
+type Disk struct {
+	Name string `json:"name"`
+	Size string `json:"size"`
+	Type string `json:"type"`
+
+	// address is empty for boot disks, which are part of the instance
+	address string
+}
+
+func (d Disk) String() string {
+	return fmt.Sprintf("%s (%sGB %s)", d.Name, d.Size, d.Type)
+}
+
+// stateNodes returns every instance in s sorted by role and name
+func stateNodes(s *terraform.State) []Node {
+	rval := []Node{}
+	if s == nil {
+		return rval
+	}
+
+	for _, m := range s.Modules {
+		disks := make(map[string]Disk)
+		for key, r := range m.Resources {
+			if r.Type != diskResource || r.Primary == nil {
+				continue
+			}
+
+			a := r.Primary.Attributes
+			disks[a["name"]] = Disk{
+				Name:    a["name"],
+				Size:    a["size"],
+				Type:    a["type"],
+				address: stateTarget(m.Path, key),
+			}
+		}
+
+		for key, r := range m.Resources {
+			if r.Type != instanceResource || r.Primary == nil {
+				continue
+			}
+
+			n := newNode(m, r.Primary.Attributes, disks)
+			n.address = stateTarget(m.Path, key)
+			rval = append(rval, n)
+		}
+	}
+
+	sort.Sort(byRole(rval))
+
+	return rval
+}
+
+func newNode(m *terraform.ModuleState, a map[string]string, disks map[string]Disk) Node {
+	n := Node{
+		Name:        a["name"],
+		Role:        a["metadata.role"],
+		Datacenter:  a["metadata.dc"],
+		Module:      stateModuleName(m.Path),
+		Zone:        a["zone"],
+		MachineType: a["machine_type"],
+		InternalIP:  a["network_interface.0.address"],
+		ExternalIP:  a["network_interface.0.access_config.0.assigned_nat_ip"],
+		SSHUser:     a["metadata.ssh_user"],
+		Disks:       []Disk{},
+	}
+
+	if n.Role == "" {
+		n.Role = n.Module
+	}
+
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("disk.%d.", i)
+		if _, ok := a[prefix+"auto_delete"]; !ok {
+			break
+		}
+
+		if name, ok := a[prefix+"disk"]; ok && name != "" {
+			if d, ok := disks[name]; ok {
+				n.Disks = append(n.Disks, d)
+			} else {
+				n.Disks = append(n.Disks, Disk{Name: name})
+			}
+			continue
+		}
+
+		n.Disks = append(n.Disks, Disk{Name: "boot", Size: a[prefix+"size"], Type: a[prefix+"type"]})
+	}
+
+	return n
+}
+
+// targets returns the addresses of the instance and its attached disks
+func (n Node) targets() []string {
+	rval := []string{n.address}
+	for _, d := range n.Disks {
+		if d.address != "" {
+			rval = append(rval, d.address)
+		}
+	}
+
+	return rval
+}
+
+type byRole []Node
+
+func (b byRole) Len() int      { return len(b) }
+func (b byRole) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byRole) Less(i, j int) bool {
+	if b[i].Role != b[j].Role {
+		return b[i].Role < b[j].Role
+	}
+	return b[i].Name < b[j].Name
+}
+
+// stateModuleName returns the dotted module path without the leading
+// root
+func stateModuleName(path []string) string {
+	if len(path) <= 1 {
+		return ""
+	}
+
+	return strings.Join(path[1:], ".")
+}
+
+// stateTarget turns the key of a resource in the state, such as
+// google_compute_disk.disk.2, into a target address with the index in
+// brackets: module.worker-nodes.google_compute_disk.disk[2]
+func stateTarget(path []string, key string) string {
+	parts := strings.Split(key, ".")
+	if len(parts) == 3 {
+		key = fmt.Sprintf("%s.%s[%s]", parts[0], parts[1], parts[2])
+	}
+
+	return resourceAddress(path, key)
+}
@@ -0,0 +1,170 @@
+package tf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+// testInstance returns an instance with a boot disk and the named disks
+// attached
+func testInstance(name, role string, disks ...string) *terraform.ResourceState {
+	a := map[string]string{
+		"name":                        name,
+		"metadata.role":               role,
+		"disk.0.auto_delete":          "true",
+		"disk.0.size":                 "10",
+		"disk.0.type":                 "pd-ssd",
+		"network_interface.0.address": "10.0.0.1",
+	}
+	for i, d := range disks {
+		a[fmt.Sprintf("disk.%d.auto_delete", i+1)] = "false"
+		a[fmt.Sprintf("disk.%d.disk", i+1)] = d
+	}
+
+	return &terraform.ResourceState{Type: instanceResource, Primary: &terraform.InstanceState{ID: name, Attributes: a}}
+}
+
+func testDisk(name string) *terraform.ResourceState {
+	return &terraform.ResourceState{Type: diskResource, Primary: &terraform.InstanceState{ID: name, Attributes: map[string]string{
+		"name": name,
+		"size": "100",
+		"type": "pd-standard",
+	}}}
+}
+
+// testNodesState has one control node (count = 1) and two worker nodes
+// (count = 2), each with a data disk
+func testNodesState() *terraform.State {
+	return &terraform.State{Modules: []*terraform.ModuleState{
+		{Path: []string{"root"}, Resources: map[string]*terraform.ResourceState{}},
+		{
+			Path: []string{"root", "control-nodes"},
+			Resources: map[string]*terraform.ResourceState{
+				"google_compute_instance.instance": testInstance("mantl-control-01", "control", "mantl-control-lvm-01"),
+				"google_compute_disk.disk":         testDisk("mantl-control-lvm-01"),
+			},
+		},
+		{
+			Path: []string{"root", "worker-nodes"},
+			Resources: map[string]*terraform.ResourceState{
+				"google_compute_instance.instance.0": testInstance("mantl-worker-01", "worker", "mantl-worker-lvm-01"),
+				"google_compute_instance.instance.1": testInstance("mantl-worker-02", "worker", "mantl-worker-lvm-02"),
+				"google_compute_disk.disk.0":         testDisk("mantl-worker-lvm-01"),
+				"google_compute_disk.disk.1":         testDisk("mantl-worker-lvm-02"),
+			},
+		},
+	}}
+}
+
+func TestStateTarget(t *testing.T) {
+	tests := []struct {
+		path   []string
+		key    string
+		target string
+	}{
+		{[]string{"root"}, "google_compute_network.network", "google_compute_network.network"},
+		{[]string{"root", "control-nodes"}, "google_compute_instance.instance", "module.control-nodes.google_compute_instance.instance"},
+		{[]string{"root", "worker-nodes"}, "google_compute_disk.disk.2", "module.worker-nodes.google_compute_disk.disk[2]"},
+		{[]string{"root", "dc1", "worker-nodes"}, "google_compute_instance.instance.0", "module.dc1.module.worker-nodes.google_compute_instance.instance[0]"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.target, stateTarget(test.path, test.key))
+	}
+}
+
+func TestStateNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := stateNodes(testNodesState())
+	if !assert.Equal(3, len(nodes)) {
+		return
+	}
+
+	control := nodes[0]
+	assert.Equal("mantl-control-01", control.Name)
+	assert.Equal("control-nodes", control.Module)
+	assert.Equal([]Disk{
+		{Name: "boot", Size: "10", Type: "pd-ssd"},
+		{Name: "mantl-control-lvm-01", Size: "100", Type: "pd-standard", address: "module.control-nodes.google_compute_disk.disk"},
+	}, control.Disks)
+
+	assert.Equal("mantl-worker-01", nodes[1].Name)
+	assert.Equal("mantl-worker-02", nodes[2].Name)
+
+	assert.Equal(0, len(stateNodes(nil)))
+}
+
+func TestSelectNodes(t *testing.T) {
+	all := stateNodes(testNodesState())
+
+	tests := []struct {
+		roles   []string
+		names   []string
+		targets []string
+	}{
+		{
+			[]string{"control"}, nil,
+			[]string{
+				"module.control-nodes.google_compute_instance.instance",
+				"module.control-nodes.google_compute_disk.disk",
+			},
+		},
+		{
+			[]string{"worker"}, nil,
+			[]string{
+				"module.worker-nodes.google_compute_instance.instance[0]",
+				"module.worker-nodes.google_compute_disk.disk[0]",
+				"module.worker-nodes.google_compute_instance.instance[1]",
+				"module.worker-nodes.google_compute_disk.disk[1]",
+			},
+		},
+		{
+			nil, []string{"worker-02"},
+			[]string{
+				"module.worker-nodes.google_compute_instance.instance[1]",
+				"module.worker-nodes.google_compute_disk.disk[1]",
+			},
+		},
+		{
+			[]string{"control"}, []string{"mantl-control-01", "worker-01"},
+			[]string{
+				"module.control-nodes.google_compute_instance.instance",
+				"module.control-nodes.google_compute_disk.disk",
+				"module.worker-nodes.google_compute_instance.instance[0]",
+				"module.worker-nodes.google_compute_disk.disk[0]",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		selected, err := selectNodes(all, test.roles, test.names)
+		if !assert.NoError(t, err, "%v %v", test.roles, test.names) {
+			continue
+		}
+
+		targets := []string{}
+		for _, n := range selected {
+			targets = append(targets, n.targets()...)
+		}
+		assert.Equal(t, test.targets, targets, "%v %v", test.roles, test.names)
+	}
+}
+
+func TestSelectNodes_Unknown(t *testing.T) {
+	all := stateNodes(testNodesState())
+
+	_, err := selectNodes(all, []string{"edge"}, nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "No nodes with role 'edge'. Roles: control, worker", err.Error())
+	}
+
+	_, err = selectNodes(all, nil, []string{"mantl-edge-01"})
+	assert.Error(t, err)
+
+	_, err = selectNodes(all, nil, []string{"worker-03"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package tf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// Variable values are resolved in this order. The first source that
+// has a value wins:
+//
+//  1. --var module.name=value flags
+//  2. --var-file files, later files override earlier ones
+//  3. PONY_VAR_<name> and TF_VAR_<name> environment variables
+//  4. the answers file or an interactive prompt, which offers the
+//     module default
+const (
+	SourceFlag     = "--var"
+	SourceVarFile  = "--var-file"
+	SourceEnv      = "environment"
+	SourceAnswers  = "answers file"
+	SourcePrompt   = "prompt"
+	SourceGlobal   = "global"
+	SourceModule   = "module config"
+	SourceState    = "state"
+	SourceDefault  = "default"
+	EnvVarPrefix   = "PONY_VAR_"
+	TfEnvVarPrefix = "TF_VAR_"
+)
+
+type overrides struct {
+	flags map[string]interface{}
+	files map[string]interface{}
+}
+
+func newOverrides() *overrides {
+	return &overrides{
+		flags: make(map[string]interface{}),
+		files: make(map[string]interface{}),
+	}
+}
+
+// SetVar adds a --var override. Names without a module prefix belong
+// to the root module.
+func (tf *Tf) SetVar(kv string) error {
+	idx := strings.Index(kv, "=")
+	if idx <= 0 {
+		return fmt.Errorf("Invalid variable '%s'. Expected module.name=value", kv)
+	}
+
+	tf.overrides.flags[overrideKey(kv[:idx])] = kv[idx+1:]
+
+	return nil
+}
+
+// AddVarFile reads a terraform style .tfvars file. Module variables use
+// quoted keys: "worker-nodes.count" = 5
+func (tf *Tf) AddVarFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	vars := make(map[string]interface{})
+	if err := hcl.Decode(&vars, string(data)); err != nil {
+		return fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+
+	for k, v := range vars {
+		tf.overrides.files[overrideKey(k)] = v
+	}
+
+	return nil
+}
+
+// lookup returns the highest priority override for a variable
+func (o *overrides) lookup(v *variable) (interface{}, string, bool) {
+	key := v.key()
+
+	if value, ok := o.flags[key]; ok {
+		return value, SourceFlag, true
+	}
+
+	if value, ok := o.files[key]; ok {
+		return value, SourceVarFile, true
+	}
+
+	for _, name := range envNames(v) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, fmt.Sprintf("%s %s", SourceEnv, name), true
+		}
+	}
+
+	return nil, "", false
+}
+
+// applyOverrides sets every variable in vs that has an override and
+// marks it fixed so no handler asks for it again
+func (tf *Tf) applyOverrides(vs *variables) error {
+	for _, v := range *vs {
+		if strings.HasPrefix(v.name, "meta_") {
+			continue
+		}
+
+		value, source, ok := tf.overrides.lookup(v)
+		if !ok {
+			continue
+		}
+
+		cv, err := v.convert(value)
+		if err != nil {
+			return fmt.Errorf("%s (from %s): %s", v.key(), source, err)
+		}
+
+		if err := v.validate(cv); err != nil {
+			return fmt.Errorf("%s (from %s)", err, source)
+		}
+
+		tf.resolve(v, cv, source)
+		v.fixed = true
+	}
+
+	return nil
+}
+
+// isOverrideSource reports whether a value came from a flag, a
+// --var-file or the environment, which always win
+func isOverrideSource(source string) bool {
+	return source == SourceFlag || source == SourceVarFile || strings.HasPrefix(source, SourceEnv+" ")
+}
+
+func (tf *Tf) setSource(v *variable, source string) {
+	tf.sources[v.key()] = source
+}
+
+// resolve sets the final value of a variable, remembers where it came
+// from and records it for --record. Sensitive values are registered
+// for redaction and kept out of the recorded answers.
+func (tf *Tf) resolve(v *variable, value interface{}, source string) {
+	v.setValue(value)
+	tf.setSource(v, source)
+	tf.cli.Record(v.module, v.name, value)
+
+	if v.sensitive {
+		tf.cli.RecordSensitive(v.module, v.name)
+		tf.redactor.add(value)
+	}
+}
+
+// PrintSources lists the final value of every variable and where it
+// came from
+func (tf *Tf) PrintSources() {
+	fmt.Println("\nVariable sources:")
+	tf.printSources(tf.tree)
+}
+
+func (tf *Tf) printSources(t *module.Tree) {
+	vs := newVariables()
+	vs.readVars(t)
+
+	names := make([]string, 0, len(*vs))
+	for name := range *vs {
+		if !strings.HasPrefix(name, "meta_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := vs.get(name)
+
+		source, ok := tf.sources[v.key()]
+		if !ok {
+			source = SourceDefault
+		}
+
+		value := tf.showValue(v)
+		fmt.Printf("  %-30s = %-30s (%s)\n", v.key(), value, source)
+	}
+
+	children := t.Children()
+	for _, m := range t.Config().Modules {
+		if child, ok := children[m.Name]; ok {
+			tf.printSources(child)
+		}
+	}
+}
+
+func overrideKey(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+
+	return module.RootName + "." + name
+}
+
+// envNames returns the environment variables checked for v. Module
+// variables use PONY_VAR_<module>_<name> with '-' and '.' replaced by
+// '_'. Root variables also honor terraform's TF_VAR_<name>.
+func envNames(v *variable) []string {
+	if v.module == module.RootName {
+		return []string{EnvVarPrefix + v.name, TfEnvVarPrefix + v.name}
+	}
+
+	r := strings.NewReplacer("-", "_", ".", "_")
+	return []string{EnvVarPrefix + r.Replace(v.module) + "_" + v.name}
+}
@@ -0,0 +1,323 @@
+package tf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config/module"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// ShowPlan collects the variables exactly like Create and prints what
+// would change without applying anything
+func (tf *Tf) ShowPlan(out string) error {
+	if err := tf.collect(); err != nil {
+		return err
+	}
+
+	if err := tf.writeRecord(); err != nil {
+		return err
+	}
+
+	if err := tf.Context(false); err != nil {
+		return err
+	}
+
+	if err := tf.Plan(); err != nil {
+		return err
+	}
+
+	tf.formatPlan(os.Stdout, tf.plan)
+
+	if out != "" {
+		// The configuration is saved with the plan so the state written
+		// by 'pony apply' holds what was planned
+		if err := tf.updateConfig(); err != nil {
+			return err
+		}
+
+		if err := tf.writePlan(out, tf.plan, tf.config); err != nil {
+			return err
+		}
+		fmt.Printf("\nPlan saved to %s. Run 'pony apply %s' to apply it\n", out, out)
+	}
+
+	return nil
+}
+
+// ApplyPlan applies a plan saved by ShowPlan without asking any
+// questions. The plan is refused if the state changed since it was
+// made.
+func (tf *Tf) ApplyPlan(path string) error {
+	p, planned, err := tf.readPlan(path)
+	if err != nil {
+		return err
+	}
+
+	if err := tf.lock("apply"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
+	current, config, err := tf.readStateFile()
+	if err != nil {
+		return err
+	}
+
+	if err := checkPlanState(tf.stateBackend().String(), p.State, current); err != nil {
+		return err
+	}
+
+	tf.tree = p.Module
+	if err := tf.readTreeSensitive(tf.tree); err != nil {
+		return err
+	}
+
+	opts, err := tf.contextOpts()
+	if err != nil {
+		return err
+	}
+
+	ctx, err := p.Context(opts)
+	if err != nil {
+		return err
+	}
+
+	tf.context = ctx
+	tf.state = current
+	tf.config = planned
+	if planned == nil {
+		// A plan written before the configuration was saved with it
+		tf.config = config
+	}
+
+	return tf.Apply()
+}
+
+// checkPlanState verifies that the state a plan was made against is the
+// current state
+func checkPlanState(path string, planned, current *terraform.State) error {
+	if stateEmpty(planned) && stateEmpty(current) {
+		return nil
+	}
+
+	if stateEmpty(planned) || stateEmpty(current) || !planned.Equal(current) {
+		return fmt.Errorf("%s changed since the plan was made. Run 'pony plan' again", path)
+	}
+
+	return nil
+}
+
+// readTreeSensitive registers the sensitive values stored in a saved
+// plan for redaction
+func (tf *Tf) readTreeSensitive(t *module.Tree) error {
+	vs := newVariables()
+	vs.readVars(t)
+
+	if err := tf.readSensitive(vs); err != nil {
+		return err
+	}
+
+	for _, child := range t.Children() {
+		if err := tf.readTreeSensitive(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// planFile is a plan and the configuration of the cluster it builds
+type planFile struct {
+	Plan []byte       `json:"plan"`
+	Pony *savedConfig `json:"pony"`
+}
+
+// writePlan saves a plan with its module tree, the resolved variables
+// and the configuration to save in the state when it is applied. It can
+// hold secrets so only the owner can read it, and it is encrypted like
+// the state.
+func (tf *Tf) writePlan(path string, p *terraform.Plan, config *savedConfig) error {
+	var buf bytes.Buffer
+	if err := terraform.WritePlan(p, &buf); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(planFile{Plan: buf.Bytes(), Pony: config})
+	if err != nil {
+		return err
+	}
+
+	key, err := tf.writeKey()
+	if err != nil {
+		return err
+	}
+
+	data, err = sealState(key, data)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// readPlan reads a plan file. The configuration is nil for a bare
+// terraform plan.
+func (tf *Tf) readPlan(path string) (*terraform.Plan, *savedConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err = tf.openState(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var f planFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Plan == nil {
+		f = planFile{Plan: data}
+	}
+
+	p, err := terraform.ReadPlan(bytes.NewReader(f.Plan))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading plan %s: %s", path, err)
+	}
+
+	return p, f.Pony, nil
+}
+
+// formatPlan prints a terraform style diff of every resource. Output
+// is coloured when w is a terminal.
+func (tf *Tf) formatPlan(w io.Writer, p *terraform.Plan) {
+	color := false
+	if f, ok := w.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		color = true
+	}
+
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	var add, change, destroy int
+
+	if p.Diff != nil {
+		for _, m := range p.Diff.Modules {
+			names := make([]string, 0, len(m.Resources))
+			for r := range m.Resources {
+				names = append(names, r)
+			}
+			sort.Strings(names)
+
+			for _, r := range names {
+				d := m.Resources[r]
+
+				var symbol, c string
+				switch d.ChangeType() {
+				case terraform.DiffCreate:
+					symbol, c = "+", colorGreen
+					add++
+				case terraform.DiffUpdate:
+					symbol, c = "~", colorYellow
+					change++
+				case terraform.DiffDestroy:
+					symbol, c = "-", colorRed
+					destroy++
+				case terraform.DiffDestroyCreate:
+					symbol, c = "-/+", colorCyan
+					add++
+					destroy++
+				default:
+					continue
+				}
+
+				fmt.Fprintf(w, "%s\n", paint(c, fmt.Sprintf("%s %s", symbol, resourceAddress(m.Path, r))))
+				if d.ChangeType() != terraform.DiffDestroy {
+					tf.formatAttributes(w, d, paint)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+	}
+
+	if add+change+destroy == 0 {
+		fmt.Fprintln(w, "No changes. The infrastructure matches the configuration.")
+		return
+	}
+
+	fmt.Fprintf(w, "Plan: %s to add, %s to change, %s to destroy.\n",
+		paint(colorGreen, fmt.Sprint(add)),
+		paint(colorYellow, fmt.Sprint(change)),
+		paint(colorRed, fmt.Sprint(destroy)),
+	)
+}
+
+func (tf *Tf) formatAttributes(w io.Writer, d *terraform.InstanceDiff, paint func(string, string) string) {
+	keys := make([]string, 0, len(d.Attributes))
+	width := 0
+	for k := range d.Attributes {
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		a := d.Attributes[k]
+
+		newValue := fmt.Sprintf("%q", a.New)
+		switch {
+		case a.Sensitive || tf.redactor.isSecret(a.New):
+			newValue = Redacted
+		case a.NewComputed:
+			newValue = "<computed>"
+		case a.NewRemoved:
+			newValue = "<removed>"
+		}
+
+		oldValue := fmt.Sprintf("%q", a.Old)
+		if a.Sensitive || tf.redactor.isSecret(a.Old) {
+			oldValue = Redacted
+		}
+
+		line := tf.redactor.Redact(fmt.Sprintf("    %-*s %s => %s", width+1, k+":", oldValue, newValue))
+		if a.RequiresNew {
+			line += paint(colorRed, " (forces new resource)")
+		}
+
+		fmt.Fprintln(w, line)
+	}
+}
+
+// resourceAddress returns the terraform address of a resource in a
+// module such as module.worker-nodes.google_compute_instance.instance.0
+func resourceAddress(path []string, name string) string {
+	parts := []string{}
+	for i, p := range path {
+		// path[0] is always root
+		if i > 0 {
+			parts = append(parts, "module", p)
+		}
+	}
+
+	return strings.Join(append(parts, name), ".")
+}
@@ -24,6 +24,13 @@ func InitPluginCmd(root *cobra.Command) {
 		Short:  "Run terraform plugin",
 		Long:   "Run terraform plugin",
 		Hidden: true,
+		// Plugins are started by terraform in the terminal's process
+		// group. They must not run the root setup, which installs the
+		// interrupt handler: a Ctrl-C has to be handled by the pony that
+		// drives terraform, not by each plugin exiting on its own.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if p.Name == "" {
 				return fmt.Errorf("Plugin name must be specified")
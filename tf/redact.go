@@ -0,0 +1,174 @@
+package tf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/cli"
+)
+
+const (
+	MetaSensitive = "meta_sensitive_variables"
+
+	Redacted = "<sensitive>"
+
+	// Shorter secrets are not replaced inside free text, which they
+	// would garble. They are still hidden wherever a whole value is
+	// shown: a variable, a plan attribute or a debug dump.
+	minSecretLength = 4
+)
+
+// redactor replaces the values of sensitive variables in anything pony
+// prints
+type redactor struct {
+	l       sync.Mutex
+	secrets []string
+	values  map[string]bool
+}
+
+func newRedactor() *redactor {
+	return &redactor{values: make(map[string]bool)}
+}
+
+func (r *redactor) add(value interface{}) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	for _, s := range valueStrings(value) {
+		if s == "" || r.values[s] {
+			continue
+		}
+
+		r.values[s] = true
+		if len(s) >= minSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+}
+
+// Redact hides every secret in s. Overlapping secrets are hidden
+// together so no part of either is revealed.
+func (r *redactor) Redact(s string) string {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	hidden := make([]bool, len(s))
+	found := false
+	for _, secret := range r.secrets {
+		for i := 0; i+len(secret) <= len(s); {
+			idx := strings.Index(s[i:], secret)
+			if idx < 0 {
+				break
+			}
+			for j := i + idx; j < i+idx+len(secret); j++ {
+				hidden[j] = true
+			}
+			found = true
+			i += idx + 1
+		}
+	}
+	if !found {
+		return s
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if !hidden[i] {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i == 0 || !hidden[i-1] {
+			b.WriteString(Redacted)
+		}
+	}
+
+	return b.String()
+}
+
+// RedactValue shows the value of a variable. A value that is or holds
+// a secret of any length is hidden entirely.
+func (r *redactor) RedactValue(value interface{}) string {
+	for _, s := range valueStrings(value) {
+		if r.isSecret(s) {
+			return Redacted
+		}
+	}
+
+	return r.Redact(fmt.Sprint(value))
+}
+
+// isSecret reports whether s is exactly a sensitive value
+func (r *redactor) isSecret(s string) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	return r.values[s]
+}
+
+func valueStrings(value interface{}) []string {
+	switch t := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		rval := make([]string, 0, len(t))
+		for _, e := range t {
+			rval = append(rval, fmt.Sprint(e))
+		}
+		return rval
+	case map[string]interface{}:
+		rval := make([]string, 0, len(t))
+		for _, e := range t {
+			rval = append(rval, fmt.Sprint(e))
+		}
+		return rval
+	}
+
+	return []string{fmt.Sprint(value)}
+}
+
+// readSensitive marks the variables listed in meta_sensitive_variables
+// and removes the meta variable
+func (tf *Tf) readSensitive(vs *variables) error {
+	sensitiveList, err := vs.getStringList(MetaSensitive)
+	if err != nil {
+		return err
+	}
+
+	for _, vname := range sensitiveList {
+		v := vs.get(vname)
+		if v == nil {
+			return fmt.Errorf("Sensitive variable '%s' not in module", vname)
+		}
+
+		v.sensitive = true
+		tf.redactor.add(v.v.Default)
+	}
+
+	vs.delete(MetaSensitive)
+
+	return nil
+}
+
+// showValue is the value of a variable as it is printed. The value of
+// a sensitive variable is never shown, whatever its length.
+func (tf *Tf) showValue(v *variable) string {
+	if v.sensitive {
+		return Redacted
+	}
+
+	return tf.redactor.RedactValue(v.v.Default)
+}
+
+// redactUi is a cli.Ui that hides sensitive values
+type redactUi struct {
+	cli.Ui
+
+	r *redactor
+}
+
+func (u *redactUi) Output(s string) { u.Ui.Output(u.r.Redact(s)) }
+func (u *redactUi) Info(s string)   { u.Ui.Info(u.r.Redact(s)) }
+func (u *redactUi) Error(s string)  { u.Ui.Error(u.r.Redact(s)) }
+func (u *redactUi) Warn(s string)   { u.Ui.Warn(u.r.Redact(s)) }
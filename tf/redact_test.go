@@ -0,0 +1,130 @@
+package tf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	tests := []struct {
+		secrets []interface{}
+		in      string
+		out     string
+	}{
+		{nil, "password=hunter2", "password=hunter2"},
+		{[]interface{}{"hunter2"}, "password=hunter2 again hunter2", "password=<sensitive> again <sensitive>"},
+		{[]interface{}{"hunter2", "hunter"}, "hunter2 hunter", "<sensitive> <sensitive>"},
+		{[]interface{}{"secret1", "t1pass"}, "secret1pass", "<sensitive>"},
+		{[]interface{}{"abcd", "cdef"}, "xabcdefy", "x<sensitive>y"},
+		{[]interface{}{"aaaa"}, "aaaaaa", "<sensitive>"},
+		{[]interface{}{[]interface{}{"token-a", "token-b"}}, "token-a,token-b", "<sensitive>,<sensitive>"},
+		{[]interface{}{map[string]interface{}{"key": "mapvalue"}}, "key=mapvalue", "key=<sensitive>"},
+		{[]interface{}{"x", "abc", nil, ""}, "x abc example", "x abc example"},
+	}
+
+	for _, test := range tests {
+		r := newRedactor()
+		for _, s := range test.secrets {
+			r.add(s)
+		}
+
+		assert.Equal(t, test.out, r.Redact(test.in), test.in)
+	}
+}
+
+func TestRedactor_RedactValue(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newRedactor()
+	r.add("x")
+	r.add("hunter2")
+	r.add([]interface{}{"ab", "long-token"})
+
+	assert.Equal(Redacted, r.RedactValue("x"))
+	assert.Equal(Redacted, r.RedactValue("hunter2"))
+	assert.Equal(Redacted, r.RedactValue([]interface{}{"ab", "cd"}))
+	assert.Equal(Redacted, r.RedactValue([]interface{}{"long-token", "cd"}))
+	assert.Equal("user:<sensitive>", r.RedactValue("user:hunter2"))
+	assert.Equal("box", r.RedactValue("box"))
+	assert.Equal("3", r.RedactValue(3))
+}
+
+func TestRedactor_ShortSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	tf := New()
+	tf.redactor.add("pw1")
+
+	// Free text keeps words that merely contain the secret
+	assert.Equal("pw1x is not pw1", tf.redactor.Redact("pw1x is not pw1"))
+
+	// A whole value is always hidden
+	assert.Equal(Redacted, tf.redactor.RedactValue("pw1"))
+
+	v := &variable{name: "pin", v: &config.Variable{Name: "pin", Default: "pw1"}, sensitive: true}
+	assert.Equal(Redacted, tf.showValue(v))
+	v.v.Default = "new"
+	assert.Equal(Redacted, tf.showValue(v))
+
+	var buf bytes.Buffer
+	tf.formatAttributes(&buf, &terraform.InstanceDiff{Attributes: map[string]*terraform.ResourceAttrDiff{
+		"password": {Old: "pw1", New: "pw2"},
+		"user":     {Old: "", New: "pw1"},
+	}}, func(c, s string) string { return s })
+
+	assert.NotContains(buf.String(), "pw1")
+	assert.Contains(buf.String(), `"pw2"`)
+}
+
+func TestRedactUi(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newRedactor()
+	r.add("hunter2")
+	r.add("x")
+
+	mock := new(cli.MockUi)
+	ui := &redactUi{Ui: mock, r: r}
+
+	ui.Output("module.root.google_sql_user.user: password: \"\" => \"hunter2\"")
+	ui.Error("Error applying: hunter2 rejected")
+
+	assert.Equal("module.root.google_sql_user.user: password: \"\" => \"<sensitive>\"\n", mock.OutputWriter.String())
+	assert.Equal("Error applying: <sensitive> rejected\n", mock.ErrorWriter.String())
+}
+
+func TestDumpVariables(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	tf := New()
+	tf.redactor.add("hunter2")
+	tf.redactor.add("pw")
+
+	tree := module.NewTree("", &config.Config{
+		Variables: []*config.Variable{
+			{Name: "password", Default: "hunter2"},
+			{Name: "pin", Default: "pw"},
+			{Name: "short_name", Default: "mantl"},
+		},
+	})
+	tf.dumpVariables(tree)
+
+	out := buf.String()
+	assert.Contains(t, out, "variable: password, value: <sensitive>")
+	assert.Contains(t, out, "variable: pin, value: <sensitive>")
+	assert.Contains(t, out, "variable: short_name, value: mantl")
+	assert.NotContains(t, out, "hunter2")
+}
@@ -0,0 +1,181 @@
+package tf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asteris-llc/pony/cli"
+
+	"github.com/hashicorp/terraform/config/module"
+)
+
+const (
+	reviewConfirm = "y"
+	reviewAbort   = "q"
+)
+
+// reviewItem is one value on the review screen. raw is the module call
+// config of a child module, which overrides the child's defaults.
+type reviewItem struct {
+	v   *variable
+	raw map[string]interface{}
+}
+
+// Review shows every collected value grouped by module before anything
+// is created. The user can confirm, edit a single value or abort. It is
+// skipped in non-interactive mode.
+func (tf *Tf) Review() error {
+	if !tf.cli.Interactive() {
+		return nil
+	}
+
+	for {
+		items, err := tf.reviewItems(tf.tree, nil)
+		if err != nil {
+			return err
+		}
+
+		tf.printReview(items)
+
+		rsp, err := tf.cli.AskRequired(fmt.Sprintf("Enter %s to continue, a number to edit a value or %s to abort", reviewConfirm, reviewAbort))
+		if cli.IsBack(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(rsp) {
+		case reviewConfirm, "yes":
+			return nil
+		case reviewAbort, "quit", "abort":
+			return fmt.Errorf("Aborted. Nothing was changed")
+		}
+
+		n, err := strconv.Atoi(rsp)
+		if err != nil || n < 1 || n > len(items) {
+			fmt.Printf("Invalid response '%s'\n", rsp)
+			continue
+		}
+
+		if err := tf.editItem(items, items[n-1]); err != nil && !cli.IsBack(err) {
+			return err
+		}
+	}
+}
+
+// reviewItems returns the editable variables of t and its children.
+// Meta and ignored variables are left out.
+func (tf *Tf) reviewItems(t *module.Tree, raw map[string]interface{}) ([]reviewItem, error) {
+	vs := newVariables()
+	vs.readVars(t)
+
+	if err := vs.readValidation(); err != nil {
+		return nil, err
+	}
+
+	if err := tf.readSensitive(vs); err != nil {
+		return nil, err
+	}
+
+	ignored, err := vs.getStringList(MetaIgnored)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range ignored {
+		vs.delete(name)
+	}
+
+	names := make([]string, 0, len(*vs))
+	for name := range *vs {
+		if !strings.HasPrefix(name, "meta_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	rval := make([]reviewItem, 0, len(names))
+	for _, name := range names {
+		rval = append(rval, reviewItem{v: vs.get(name), raw: raw})
+	}
+
+	children := t.Children()
+	for _, m := range t.Config().Modules {
+		child, ok := children[m.Name]
+		if !ok {
+			continue
+		}
+
+		items, err := tf.reviewItems(child, m.RawConfig.Raw)
+		if err != nil {
+			return nil, err
+		}
+		rval = append(rval, items...)
+	}
+
+	return rval, nil
+}
+
+func (tf *Tf) printReview(items []reviewItem) {
+	fmt.Println("\nReview the configuration:")
+
+	current := ""
+	for i, item := range items {
+		if item.v.module != current {
+			current = item.v.module
+			fmt.Printf("\n%s\n", current)
+		}
+
+		value := tf.showValue(item.v)
+		fmt.Printf("  %3d %-20s = %s\n", i+1, item.v.name, value)
+	}
+	fmt.Println()
+}
+
+// editItem asks for a new value. The module call config is updated as
+// well so the new value is not hidden by the old one. A root value that
+// child modules copied as a global is changed in those modules too,
+// unless they were given a value of their own.
+func (tf *Tf) editItem(items []reviewItem, item reviewItem) error {
+	v := item.v
+	old := fmt.Sprint(v.v.Default)
+
+	rsp, err := tf.promptFor(v)
+	if err != nil {
+		return err
+	}
+
+	tf.setItem(item, rsp, SourcePrompt)
+
+	if v.module != module.RootName {
+		return nil
+	}
+
+	for _, child := range items {
+		c := child.v
+		if c.module == module.RootName || c.name != v.name {
+			continue
+		}
+
+		source := tf.sources[c.key()]
+		if isOverrideSource(source) {
+			continue
+		}
+
+		if source == SourceGlobal || fmt.Sprint(c.v.Default) == old {
+			tf.setItem(child, rsp, SourceGlobal)
+		}
+	}
+
+	return nil
+}
+
+func (tf *Tf) setItem(item reviewItem, value interface{}, source string) {
+	tf.resolve(item.v, value, source)
+
+	if _, ok := item.raw[item.v.name]; ok {
+		item.raw[item.v.name] = value
+	}
+}
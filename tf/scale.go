@@ -0,0 +1,110 @@
+package tf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Scale changes the node count of one role of an existing cluster. The
+// rest of the configuration comes from the configuration saved in the
+// state and only the module of that role is planned.
+func (tf *Tf) Scale(role string, count int, yes bool) error {
+	state, config, err := tf.readStateFile()
+	if err != nil {
+		return err
+	}
+	if stateEmpty(state) {
+		return fmt.Errorf("No cluster found in %s", tf.stateBackend())
+	}
+	if config == nil {
+		return fmt.Errorf("No saved configuration in %s. Only clusters created by this version of pony can be scaled", tf.stateBackend())
+	}
+	tf.state = state
+
+	if err := tf.useConfig(config); err != nil {
+		return err
+	}
+
+	if err := tf.LoadCloud(); err != nil {
+		return err
+	}
+
+	name, err := tf.roleModule(role)
+	if err != nil {
+		return err
+	}
+
+	if err := tf.SetVar(fmt.Sprintf("%s.count=%d", name, count)); err != nil {
+		return err
+	}
+
+	if err := tf.ReadVariables(create_metaHandlers); err != nil {
+		return err
+	}
+
+	if err := tf.cli.CheckAnswers(); err != nil {
+		return err
+	}
+
+	if tf.showSources {
+		tf.PrintSources()
+	}
+
+	tf.targets = []string{"module." + name}
+
+	if err := tf.lockState("scale"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
+	if err := tf.Context(false); err != nil {
+		return err
+	}
+
+	if err := tf.Plan(); err != nil {
+		return err
+	}
+
+	tf.formatPlan(os.Stdout, tf.plan)
+
+	if tf.plan.Diff == nil || tf.plan.Diff.Empty() {
+		return nil
+	}
+
+	// The saved configuration answered every question. Confirm on the
+	// terminal.
+	tf.SetAnswers(nil)
+	if !yes && !tf.cli.AskYesNo(fmt.Sprintf("Scale %s to %d nodes? (y/N)", role, count), "n") {
+		return fmt.Errorf("Aborted. Nothing was changed")
+	}
+
+	if err := tf.updateConfig(); err != nil {
+		return err
+	}
+
+	return tf.Apply()
+}
+
+// roleModule returns the name of the module that creates the nodes of
+// a role
+func (tf *Tf) roleModule(role string) (string, error) {
+	roles := []string{}
+
+	for _, m := range tf.tree.Config().Modules {
+		r, ok := m.RawConfig.Raw["role"].(string)
+		if !ok {
+			continue
+		}
+
+		if r == role {
+			if _, ok := m.RawConfig.Raw["count"]; !ok {
+				return "", fmt.Errorf("Module %s for role '%s' has no count", m.Name, role)
+			}
+			return m.Name, nil
+		}
+		roles = append(roles, r)
+	}
+
+	return "", fmt.Errorf("Unknown role '%s'. Roles: %s", role, strings.Join(roles, ", "))
+}
@@ -0,0 +1,255 @@
+package tf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hashicorp/terraform/config/module"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const (
+	// Private key matching the ssh_key default of the builtin modules
+	DefaultSSHKey = "~/.ssh/id_rsa"
+)
+
+var nodeIndexRegexp = regexp.MustCompile(`^(.+)-(\d+)$`)
+
+// SSH connects to one node. target is a node name, <role>-<NN> such as
+// worker-02 or a role and a 1-based index such as control 1. The ssh
+// client replaces pony so the session behaves exactly like plain ssh.
+// An empty key selects the private key of the cluster's ssh_key.
+func (tf *Tf) SSH(key string, target []string, command []string) error {
+	state, key, err := tf.sshState(key)
+	if err != nil {
+		return err
+	}
+
+	n, err := findNode(stateNodes(state), target)
+	if err != nil {
+		return err
+	}
+
+	if n.ExternalIP == "" {
+		return fmt.Errorf("Node %s has no external IP", n.Name)
+	}
+
+	path, err := exec.LookPath("ssh")
+	if err != nil {
+		return err
+	}
+
+	tf.Clean()
+
+	return syscall.Exec(path, append([]string{"ssh"}, sshArgs(n, key, command)...), os.Environ())
+}
+
+// SSHRole runs a command on every node of a role in parallel. Each line
+// of output is prefixed with the node name.
+func (tf *Tf) SSHRole(key, role string, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("A command is required with --all-role")
+	}
+
+	state, key, err := tf.sshState(key)
+	if err != nil {
+		return err
+	}
+
+	nodes := []Node{}
+	for _, n := range stateNodes(state) {
+		if n.Role == role {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("No nodes with role '%s'", role)
+	}
+
+	var l sync.Mutex
+	var wg sync.WaitGroup
+	failed := make([]string, 0)
+
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n Node) {
+			defer wg.Done()
+
+			if err := runPrefixed(n, key, command, &l); err != nil {
+				l.Lock()
+				failed = append(failed, fmt.Sprintf("%s: %s", n.Name, err))
+				l.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Command failed on %d of %d nodes:\n  %s", len(failed), len(nodes), strings.Join(failed, "\n  "))
+	}
+
+	return nil
+}
+
+// sshState reads the state and picks the private key. Without a key
+// given it is the saved ssh_key with the .pub extension removed.
+func (tf *Tf) sshState(key string) (*terraform.State, string, error) {
+	state, config, err := tf.readStateFile()
+	if err != nil {
+		return nil, "", err
+	}
+	if state == nil {
+		return nil, "", fmt.Errorf("No state found at %s", tf.stateBackend())
+	}
+
+	if key == "" {
+		key = savedSSHKey(config)
+	}
+
+	return state, key, nil
+}
+
+func savedSSHKey(c *savedConfig) string {
+	if c == nil {
+		return DefaultSSHKey
+	}
+
+	pub, ok := c.Variables[module.RootName]["ssh_key"].(string)
+	if !ok || !strings.HasSuffix(pub, ".pub") {
+		return DefaultSSHKey
+	}
+
+	return strings.TrimSuffix(pub, ".pub")
+}
+
+func runPrefixed(n Node, key string, command []string, l *sync.Mutex) error {
+	if n.ExternalIP == "" {
+		return fmt.Errorf("No external IP")
+	}
+
+	cmd := exec.Command("ssh", append([]string{"-o", "BatchMode=yes"}, sshArgs(n, key, command)...)...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go prefixLines(&wg, l, n.Name, stdout, os.Stdout)
+	go prefixLines(&wg, l, n.Name, stderr, os.Stderr)
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+func prefixLines(wg *sync.WaitGroup, l *sync.Mutex, prefix string, r io.Reader, w io.Writer) {
+	defer wg.Done()
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l.Lock()
+		fmt.Fprintf(w, "%s: %s\n", prefix, s.Text())
+		l.Unlock()
+	}
+}
+
+func sshArgs(n Node, key string, command []string) []string {
+	args := []string{"-i", expandHome(key)}
+	if n.SSHUser != "" {
+		args = append(args, "-l", n.SSHUser)
+	}
+	args = append(args, n.ExternalIP)
+
+	return append(args, command...)
+}
+
+// findNode resolves the target of pony ssh. A <role>-<NN> target must
+// name the role of the node, and is an error when nodes of several
+// modules share the role and number.
+func findNode(nodes []Node, target []string) (Node, error) {
+	switch len(target) {
+	case 1:
+		for _, n := range nodes {
+			if n.Name == target[0] {
+				return n, nil
+			}
+		}
+
+		if m := nodeIndexRegexp.FindStringSubmatch(target[0]); m != nil {
+			matches := []string{}
+			var found Node
+			for _, n := range nodes {
+				if n.Role == m[1] && strings.HasSuffix(n.Name, "-"+target[0]) {
+					matches = append(matches, n.Name)
+					found = n
+				}
+			}
+
+			switch len(matches) {
+			case 0:
+				return nodeByIndex(nodes, m[1], m[2])
+			case 1:
+				return found, nil
+			default:
+				return Node{}, fmt.Errorf("'%s' matches several nodes: %s. Use the full node name", target[0], strings.Join(matches, ", "))
+			}
+		}
+	case 2:
+		return nodeByIndex(nodes, target[0], target[1])
+	default:
+		return Node{}, fmt.Errorf("Expected a node name or a role and index")
+	}
+
+	return Node{}, fmt.Errorf("No node named '%s'", target[0])
+}
+
+func nodeByIndex(nodes []Node, role, index string) (Node, error) {
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return Node{}, fmt.Errorf("Invalid node index '%s'", index)
+	}
+
+	count := 0
+	for _, n := range nodes {
+		if n.Role != role {
+			continue
+		}
+
+		count++
+		if count == i {
+			return n, nil
+		}
+	}
+
+	if count == 0 {
+		return Node{}, fmt.Errorf("No nodes with role '%s'", role)
+	}
+
+	return Node{}, fmt.Errorf("Role '%s' has %d nodes. Index must be between 1 and %d", role, count, count)
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+
+	return path
+}
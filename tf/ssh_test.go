@@ -0,0 +1,60 @@
+package tf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindNode(t *testing.T) {
+	nodes := []Node{
+		{Name: "mantl-control-01", Role: "control"},
+		{Name: "mantl-control-02", Role: "control"},
+		{Name: "mantl-worker-01", Role: "worker", Module: "dc1-worker-nodes"},
+		{Name: "other-worker-01", Role: "worker", Module: "dc2-worker-nodes"},
+		{Name: "mantl-worker-03", Role: "worker", Module: "dc1-worker-nodes"},
+	}
+
+	tests := []struct {
+		target []string
+		name   string
+		err    bool
+	}{
+		{[]string{"mantl-control-02"}, "mantl-control-02", false},
+		{[]string{"control-02"}, "mantl-control-02", false},
+		{[]string{"control", "1"}, "mantl-control-01", false},
+		{[]string{"worker-03"}, "mantl-worker-03", false},
+		{[]string{"worker-01"}, "", true},
+		{[]string{"01"}, "", true},
+		{[]string{"control-2"}, "mantl-control-02", false},
+		{[]string{"control-05"}, "", true},
+		{[]string{"edge", "1"}, "", true},
+		{[]string{"control", "x"}, "", true},
+		{[]string{"control", "1", "2"}, "", true},
+	}
+
+	for _, test := range tests {
+		n, err := findNode(nodes, test.target)
+		if test.err {
+			assert.Error(t, err, "%v", test.target)
+			continue
+		}
+
+		if assert.NoError(t, err, "%v", test.target) {
+			assert.Equal(t, test.name, n.Name, "%v", test.target)
+		}
+	}
+}
+
+func TestSavedSSHKey(t *testing.T) {
+	config := func(key interface{}) *savedConfig {
+		return &savedConfig{Variables: map[string]map[string]interface{}{
+			"root": {"ssh_key": key},
+		}}
+	}
+
+	assert.Equal(t, DefaultSSHKey, savedSSHKey(nil))
+	assert.Equal(t, "~/.ssh/mantl", savedSSHKey(config("~/.ssh/mantl.pub")))
+	assert.Equal(t, DefaultSSHKey, savedSSHKey(config("~/.ssh/mantl")))
+	assert.Equal(t, DefaultSSHKey, savedSSHKey(&savedConfig{}))
+}
@@ -0,0 +1,192 @@
+package tf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/asteris-llc/pony/tf/backend"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func (tf *Tf) writeState() error {
+	data, err := json.MarshalIndent(stateFile{State: tf.state, Pony: tf.config}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	// A state read in the clear is encrypted for the first time
+	first := tf.stateKey == nil
+
+	key, err := tf.writeKey()
+	if err != nil {
+		return err
+	}
+
+	data, err = sealState(key, data)
+	if err != nil {
+		return err
+	}
+
+	b := tf.stateOutBackend()
+	tf.startWrite()
+	if err := b.Write(data); err != nil {
+		return err
+	}
+
+	if first && key != nil {
+		if err := resealVersions(b, nil, key); err != nil {
+			return err
+		}
+	}
+
+	return tf.pinEnv()
+}
+
+// SetStatePath sets the state file read and written by every command
+func (tf *Tf) SetStatePath(path string) {
+	tf.statePath = path
+}
+
+// SetStateOut writes the state to a different file than it is read
+// from
+func (tf *Tf) SetStateOut(path string) {
+	tf.stateOut = path
+}
+
+// SetBackend stores the state in a backend instead of the --state file.
+// The local backend defaults to the --state file and history. Remote
+// states are kept apart per environment, so SetEnv must come first.
+func (tf *Tf) SetBackend(name string, config map[string]string) error {
+	if name == "" {
+		name = "local"
+	}
+
+	config, err := backend.ForEnv(name, config, tf.env)
+	if err != nil {
+		return err
+	}
+
+	if name == "local" {
+		defaults := map[string]string{
+			"path":    tf.statePath,
+			"history": strconv.Itoa(tf.stateHistory),
+		}
+		for k, v := range config {
+			defaults[k] = v
+		}
+		config = defaults
+	}
+
+	b, err := backend.New(name, config)
+	if err != nil {
+		return err
+	}
+	tf.backend = b
+
+	return nil
+}
+
+// stateBackend returns the backend the state is read from and locked in
+func (tf *Tf) stateBackend() backend.Backend {
+	if tf.backend == nil {
+		return &backend.Local{Path: tf.statePath, History: tf.stateHistory}
+	}
+
+	return tf.backend
+}
+
+func (tf *Tf) stateOutBackend() backend.Backend {
+	if tf.stateOut != "" {
+		return &backend.Local{Path: tf.stateOut, History: tf.stateHistory}
+	}
+
+	return tf.stateBackend()
+}
+
+// stateFile is a terraform state with the configuration of the cluster
+// added under "pony". Terraform ignores the extra key.
+type stateFile struct {
+	*terraform.State
+
+	Pony *savedConfig `json:"pony,omitempty"`
+}
+
+// readState reads the state. A missing state is not an error and
+// returns nil.
+func (tf *Tf) readState() (*terraform.State, error) {
+	s, _, err := tf.readStateFile()
+	return s, err
+}
+
+// readStateFile reads the state and the configuration saved in it,
+// which is nil for a state written by an older pony
+func (tf *Tf) readStateFile() (*terraform.State, *savedConfig, error) {
+	data, err := tf.stateBackend().Read()
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+
+	data, err = tf.openState(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, config, err := parseStateFile(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tf.checkEnv(s); err != nil {
+		return nil, nil, err
+	}
+
+	return s, config, nil
+}
+
+func parseStateFile(data []byte) (*terraform.State, *savedConfig, error) {
+	s, err := terraform.ReadState(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var f struct {
+		Pony *savedConfig `json:"pony"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, err
+	}
+
+	return s, f.Pony, nil
+}
+
+// requireState reads a state that must exist
+func (tf *Tf) requireState() (*terraform.State, error) {
+	s, err := tf.readState()
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("No state found at %s", tf.stateBackend())
+	}
+
+	return s, nil
+}
+
+// stateEmpty reports whether a state has no resources. A destroyed
+// cluster leaves an empty root module behind.
+func stateEmpty(s *terraform.State) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, m := range s.Modules {
+		if len(m.Resources) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,348 @@
+package tf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/asteris-llc/pony/tf/backend"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// An encrypted state is written as a small JSON document holding the
+// sealed state. The key is a passphrase or the contents of a key file,
+// both stretched with scrypt.
+const (
+	StateKeyFileEnv  = "PONY_STATE_KEY_FILE"
+	NewPassphraseEnv = "PONY_NEW_PASSPHRASE"
+
+	stateEncryption = "scrypt-aes256-gcm"
+
+	keyPassphrase = "passphrase"
+	keyFile       = "key-file"
+)
+
+type encryptedState struct {
+	Encryption string `json:"pony_encryption"`
+	Key        string `json:"key"`
+	Data       []byte `json:"data"`
+}
+
+// stateKey is the key a state is encrypted with
+type stateKey struct {
+	kind   string
+	secret string
+}
+
+// SetStateEncryption encrypts the state when it is written. A state
+// that was read encrypted is always written encrypted with the same
+// key.
+func (tf *Tf) SetStateEncryption(encrypt bool, keyFile string) {
+	tf.encryptState = encrypt
+	tf.stateKeyFile = keyFile
+}
+
+// writeKey returns the key for writing the state or nil to write it in
+// the clear
+func (tf *Tf) writeKey() (*stateKey, error) {
+	switch {
+	case tf.stateKey != nil:
+		return tf.stateKey, nil
+	case tf.stateKeyFile != "":
+		key, err := readKeyFile(tf.stateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tf.stateKey = key
+	case tf.encryptState:
+		pass, err := tf.newPassphrase(PassphraseEnv, "the state")
+		if err != nil {
+			return nil, err
+		}
+		tf.stateKey = &stateKey{kind: keyPassphrase, secret: pass}
+	}
+
+	return tf.stateKey, nil
+}
+
+// readKey returns the key for reading a state encrypted with kind
+func (tf *Tf) readKey(kind string) (*stateKey, error) {
+	if tf.stateKey != nil && tf.stateKey.kind == kind {
+		return tf.stateKey, nil
+	}
+
+	return tf.askKey(kind)
+}
+
+func (tf *Tf) askKey(kind string) (*stateKey, error) {
+	switch kind {
+	case keyFile:
+		if tf.stateKeyFile == "" {
+			return nil, fmt.Errorf("The state is encrypted with a key file. Pass it with --state-key-file or $%s", StateKeyFileEnv)
+		}
+		return readKeyFile(tf.stateKeyFile)
+	case keyPassphrase:
+		pass, err := tf.passphrase("Passphrase for the state")
+		if err != nil {
+			return nil, err
+		}
+		if pass == "" {
+			return nil, fmt.Errorf("The state is encrypted. Set %s or run pony interactively", PassphraseEnv)
+		}
+		return &stateKey{kind: keyPassphrase, secret: pass}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown state key '%s'", kind)
+}
+
+func readKeyFile(path string) (*stateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return nil, fmt.Errorf("Key file %s is empty", path)
+	}
+
+	return &stateKey{kind: keyFile, secret: secret}, nil
+}
+
+// newPassphrase reads a new passphrase for what from env or asks for
+// it twice
+func (tf *Tf) newPassphrase(env, what string) (string, error) {
+	if p := os.Getenv(env); p != "" {
+		return p, nil
+	}
+
+	if !tf.cli.Interactive() {
+		return "", fmt.Errorf("No passphrase to encrypt %s with. Set %s", what, env)
+	}
+
+	for {
+		pass, err := tf.cli.AskSecret("New passphrase for "+what, "")
+		if err != nil {
+			return "", err
+		}
+
+		again, err := tf.cli.AskSecret("Repeat the passphrase", "")
+		if err != nil {
+			return "", err
+		}
+
+		if pass == again {
+			return pass, nil
+		}
+
+		fmt.Println("The passphrases do not match")
+	}
+}
+
+// sealState encrypts a serialized state. A nil key leaves it alone.
+func sealState(key *stateKey, data []byte) ([]byte, error) {
+	if key == nil {
+		return data, nil
+	}
+
+	sealed, err := encrypt(key.secret, data)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(encryptedState{
+		Encryption: stateEncryption,
+		Key:        key.kind,
+		Data:       sealed,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
+
+// encrypted returns the envelope of an encrypted state or nil for a
+// state in the clear
+func encrypted(data []byte) (*encryptedState, error) {
+	var e encryptedState
+	if err := json.Unmarshal(data, &e); err != nil || e.Encryption == "" {
+		return nil, nil
+	}
+
+	if e.Encryption != stateEncryption {
+		return nil, fmt.Errorf("Unsupported state encryption '%s'", e.Encryption)
+	}
+
+	return &e, nil
+}
+
+// openState decrypts a serialized state if it is encrypted and
+// remembers the key so the state is written back encrypted
+func (tf *Tf) openState(data []byte) ([]byte, error) {
+	e, err := encrypted(data)
+	if err != nil || e == nil {
+		return data, err
+	}
+
+	key, err := tf.readKey(e.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decrypt(key.secret, e.Data)
+	if err != nil && key == tf.stateKey {
+		// Encrypted with an older key, e.g. a backup made before a rekey
+		if key, err = tf.askKey(e.Key); err == nil {
+			plaintext, err = decrypt(key.secret, e.Data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	tf.stateKey = key
+
+	return plaintext, nil
+}
+
+// openWith decrypts a serialized state with key without asking for
+// anything. A nil key only opens a state in the clear.
+func openWith(key *stateKey, data []byte) ([]byte, error) {
+	e, err := encrypted(data)
+	if err != nil || e == nil {
+		return data, err
+	}
+
+	if key == nil || key.kind != e.Key {
+		return nil, fmt.Errorf("Encrypted with another key")
+	}
+
+	return decrypt(key.secret, e.Data)
+}
+
+// sealedWith reports whether data is sealed with key, or in the clear
+// for a nil key
+func sealedWith(key *stateKey, data []byte) bool {
+	e, err := encrypted(data)
+	if err != nil || (e == nil) != (key == nil) {
+		return false
+	}
+
+	_, err = openWith(key, data)
+	return err == nil
+}
+
+// resealVersions encrypts the backup and history of a local state with
+// key so no old version stays in the clear or under a replaced key.
+// Versions that old does not open are never removed: they are left as
+// they are and stay readable with the key they were sealed with.
+func resealVersions(b backend.Backend, old, key *stateKey) error {
+	l, ok := b.(*backend.Local)
+	if !ok {
+		return nil
+	}
+
+	kept := 0
+	err := l.RewriteVersions(func(data []byte) ([]byte, error) {
+		if sealedWith(key, data) {
+			return nil, nil
+		}
+
+		plaintext, err := openWith(old, data)
+		if err != nil {
+			kept++
+			return nil, nil
+		}
+
+		return sealState(key, plaintext)
+	})
+	if err != nil {
+		return err
+	}
+
+	if kept > 0 {
+		log.Warnf("%d old versions of %s are encrypted with an earlier key and were left as they are", kept, l.Path)
+	}
+
+	return nil
+}
+
+// RekeyState encrypts the state with a new passphrase, a new key file
+// or, with clear, not at all
+func (tf *Tf) RekeyState(newKeyFile string, clear bool) error {
+	if clear && newKeyFile != "" {
+		return fmt.Errorf("Use either a new key file or --decrypt")
+	}
+
+	if err := tf.lock("rekey"); err != nil {
+		return err
+	}
+	defer tf.unlock()
+
+	b := tf.stateBackend()
+
+	data, err := b.Read()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("No state found at %s", b)
+	}
+
+	plaintext, err := tf.openState(data)
+	if err != nil {
+		return err
+	}
+
+	var key *stateKey
+	switch {
+	case clear:
+	case newKeyFile != "":
+		key, err = readKeyFile(newKeyFile)
+	default:
+		var pass string
+		pass, err = tf.newPassphrase(NewPassphraseEnv, "the state")
+		key = &stateKey{kind: keyPassphrase, secret: pass}
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err = sealState(key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	// The versions are resealed first so a failure leaves the state
+	// under its old key. The rekeyed state is the same state and does
+	// not become a version of its own.
+	tf.startWrite()
+	if err := resealVersions(b, tf.stateKey, key); err != nil {
+		return err
+	}
+
+	if l, ok := b.(*backend.Local); ok {
+		err = l.Replace(data)
+	} else {
+		err = b.Write(data)
+	}
+	if err != nil {
+		return err
+	}
+	tf.stateKey = key
+
+	if key == nil {
+		fmt.Printf("%s is no longer encrypted\n", b)
+	} else {
+		fmt.Printf("%s is encrypted with the new %s\n", b, key.kind)
+	}
+
+	if _, ok := b.(*backend.Local); !ok {
+		fmt.Printf("Versions kept by the %s service itself are not changed\n", b)
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package tf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asteris-llc/pony/tf/backend"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStateTf returns a Tf with its state in a temporary directory
+func testStateTf(t *testing.T) (*Tf, string) {
+	dir, err := ioutil.TempDir("", "pony")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tf := New()
+	tf.SetStatePath(filepath.Join(dir, StatePath))
+
+	return tf, dir
+}
+
+func writeKeyFile(t *testing.T, dir, name, secret string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSealState(t *testing.T) {
+	assert := assert.New(t)
+
+	data := []byte(`{"version": 3}`)
+
+	clear, err := sealState(nil, data)
+	assert.NoError(err)
+	assert.Equal(data, clear)
+
+	key := &stateKey{kind: keyPassphrase, secret: "right"}
+	sealed, err := sealState(key, data)
+	assert.NoError(err)
+	assert.False(string(sealed) == string(data))
+
+	e, err := encrypted(sealed)
+	assert.NoError(err)
+	assert.NotNil(e)
+
+	opened, err := openWith(key, sealed)
+	assert.NoError(err)
+	assert.Equal(data, opened)
+
+	_, err = openWith(&stateKey{kind: keyPassphrase, secret: "wrong"}, sealed)
+	assert.Error(err)
+
+	_, err = openWith(&stateKey{kind: keyFile, secret: "right"}, sealed)
+	assert.Error(err)
+
+	_, err = openWith(nil, sealed)
+	assert.Error(err)
+
+	// A state in the clear opens without a key
+	opened, err = openWith(nil, data)
+	assert.NoError(err)
+	assert.Equal(data, opened)
+}
+
+func TestOpenState_Passphrase(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv(PassphraseEnv)
+
+	sealed, err := sealState(&stateKey{kind: keyPassphrase, secret: "right"}, []byte("state"))
+	assert.NoError(err)
+
+	os.Setenv(PassphraseEnv, "wrong")
+	tf := New()
+	_, err = tf.openState(sealed)
+	assert.Error(err)
+	assert.Nil(tf.stateKey)
+
+	os.Setenv(PassphraseEnv, "right")
+	tf = New()
+	opened, err := tf.openState(sealed)
+	assert.NoError(err)
+	assert.Equal("state", string(opened))
+	assert.Equal(keyPassphrase, tf.stateKey.kind)
+}
+
+func TestOpenState_KeyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	tf, dir := testStateTf(t)
+	defer os.RemoveAll(dir)
+
+	path := writeKeyFile(t, dir, "key", "secret")
+	tf.SetStateEncryption(false, path)
+
+	key, err := tf.writeKey()
+	assert.NoError(err)
+	assert.Equal(keyFile, key.kind)
+
+	sealed, err := sealState(key, []byte("state"))
+	assert.NoError(err)
+
+	// Without the key file the state cannot be read
+	_, err = New().openState(sealed)
+	assert.Error(err)
+
+	other := New()
+	other.SetStateEncryption(false, writeKeyFile(t, dir, "other", "not the secret"))
+	_, err = other.openState(sealed)
+	assert.Error(err)
+
+	reader := New()
+	reader.SetStateEncryption(false, path)
+	opened, err := reader.openState(sealed)
+	assert.NoError(err)
+	assert.Equal("state", string(opened))
+}
+
+// assertVersions checks that the state and every backup open with key
+func assertVersions(t *testing.T, tf *Tf, key *stateKey, want string) {
+	assert := assert.New(t)
+
+	l := tf.stateBackend().(*backend.Local)
+
+	data, err := ioutil.ReadFile(l.Path)
+	assert.NoError(err)
+	opened, err := openWith(key, data)
+	assert.NoError(err)
+	assert.Equal(want, string(opened))
+
+	versions, err := l.Versions()
+	assert.NoError(err)
+	assert.True(len(versions) > 0)
+
+	for _, v := range versions {
+		data, err := ioutil.ReadFile(v)
+		assert.NoError(err)
+
+		e, err := encrypted(data)
+		assert.NoError(err)
+		assert.Equal(key != nil, e != nil, v)
+
+		_, err = openWith(key, data)
+		assert.NoError(err, v)
+	}
+}
+
+func TestRekeyState(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv(NewPassphraseEnv)
+	defer os.Unsetenv(PassphraseEnv)
+
+	tf, dir := testStateTf(t)
+	defer os.RemoveAll(dir)
+	tf.SetStateHistory(5)
+
+	// Two states in the clear leave a backup and a history in the clear
+	l := tf.stateBackend()
+	assert.NoError(l.Write([]byte("first")))
+	assert.NoError(l.Write([]byte("second")))
+	assertVersions(t, tf, nil, "second")
+
+	os.Setenv(NewPassphraseEnv, "new")
+	assert.NoError(tf.RekeyState("", false))
+	pass := &stateKey{kind: keyPassphrase, secret: "new"}
+	assertVersions(t, tf, pass, "second")
+
+	// A new run opens the state with the passphrase and moves it to a
+	// key file
+	os.Setenv(PassphraseEnv, "new")
+	tf2 := New()
+	tf2.SetStatePath(tf.statePath)
+	tf2.SetStateHistory(5)
+	path := writeKeyFile(t, dir, "key", "secret")
+	assert.NoError(tf2.RekeyState(path, false))
+	assertVersions(t, tf2, &stateKey{kind: keyFile, secret: "secret"}, "second")
+
+	_, err := openWith(pass, mustRead(t, tf.statePath))
+	assert.Error(err)
+
+	assert.Error(tf2.RekeyState(path, true))
+
+	tf3 := New()
+	tf3.SetStatePath(tf.statePath)
+	tf3.SetStateEncryption(false, path)
+	assert.NoError(tf3.RekeyState("", true))
+	assertVersions(t, tf3, nil, "second")
+}
+
+func TestWriteState_FirstEncryption(t *testing.T) {
+	assert := assert.New(t)
+
+	tf, dir := testStateTf(t)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(tf.stateBackend().Write([]byte("clear")))
+
+	tf.SetStateEncryption(false, writeKeyFile(t, dir, "key", "secret"))
+	assert.NoError(tf.writeState())
+
+	data := mustRead(t, tf.statePath+backend.BackupSuffix)
+	e, err := encrypted(data)
+	assert.NoError(err)
+	assert.NotNil(e)
+
+	opened, err := openWith(&stateKey{kind: keyFile, secret: "secret"}, data)
+	assert.NoError(err)
+	assert.Equal("clear", string(opened))
+}
+
+// writeMixedHistory writes a history with a version sealed with an
+// older key, a version in the clear and then the state itself
+func writeMixedHistory(t *testing.T, tf *Tf, state []byte) []byte {
+	older, err := sealState(&stateKey{kind: keyPassphrase, secret: "older"}, []byte("older"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := tf.stateBackend()
+	for _, data := range [][]byte{older, []byte("clear"), state} {
+		if err := l.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return older
+}
+
+// checkMixedHistory checks that the version sealed with the older key
+// is kept unchanged and every other version opens with key
+func checkMixedHistory(t *testing.T, tf *Tf, older []byte, key *stateKey) int {
+	assert := assert.New(t)
+
+	versions, err := tf.stateBackend().(*backend.Local).Versions()
+	assert.NoError(err)
+
+	kept := 0
+	for _, v := range versions {
+		data := mustRead(t, v)
+		if bytes.Equal(data, older) {
+			kept++
+			continue
+		}
+
+		e, err := encrypted(data)
+		assert.NoError(err)
+		assert.NotNil(e, v)
+
+		_, err = openWith(key, data)
+		assert.NoError(err, v)
+	}
+	assert.Equal(1, kept)
+
+	return len(versions)
+}
+
+func TestWriteState_MixedHistory(t *testing.T) {
+	tf, dir := testStateTf(t)
+	defer os.RemoveAll(dir)
+	tf.SetStateHistory(5)
+
+	older := writeMixedHistory(t, tf, []byte("current"))
+
+	tf.SetStateEncryption(false, writeKeyFile(t, dir, "key", "secret"))
+	assert.NoError(t, tf.writeState())
+
+	checkMixedHistory(t, tf, older, &stateKey{kind: keyFile, secret: "secret"})
+}
+
+func TestRekeyState_MixedHistory(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv(NewPassphraseEnv)
+
+	tf, dir := testStateTf(t)
+	defer os.RemoveAll(dir)
+	tf.SetStateHistory(5)
+
+	older := writeMixedHistory(t, tf, []byte("current"))
+
+	versions, err := tf.stateBackend().(*backend.Local).Versions()
+	assert.NoError(err)
+
+	os.Setenv(NewPassphraseEnv, "new")
+	assert.NoError(tf.RekeyState("", false))
+
+	// The rekeyed state is not kept as a version of its own
+	pass := &stateKey{kind: keyPassphrase, secret: "new"}
+	assert.Equal(len(versions), checkMixedHistory(t, tf, older, pass))
+
+	opened, err := openWith(pass, mustRead(t, tf.statePath))
+	assert.NoError(err)
+	assert.Equal("current", string(opened))
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
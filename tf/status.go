@@ -0,0 +1,86 @@
+package tf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Root module outputs shown by Status
+var statusOutputs = []string{"cloud", "project", "region"}
+
+type status struct {
+	Outputs map[string]interface{} `json:"outputs"`
+	Nodes   []Node                 `json:"nodes"`
+}
+
+// Status prints the nodes in the state grouped by role
+func (tf *Tf) Status(asJSON bool) error {
+	state, err := tf.requireState()
+	if err != nil {
+		return err
+	}
+
+	s := status{
+		Outputs: make(map[string]interface{}),
+		Nodes:   stateNodes(state),
+	}
+
+	if root := state.RootModule(); root != nil {
+		for _, name := range statusOutputs {
+			if o, ok := root.Outputs[name]; ok {
+				s.Outputs[name] = o.Value
+			}
+		}
+	}
+
+	if asJSON {
+		return printJSON(s)
+	}
+
+	printStatus(os.Stdout, s)
+
+	return nil
+}
+
+func printStatus(out io.Writer, s status) {
+	for _, name := range statusOutputs {
+		if v, ok := s.Outputs[name]; ok {
+			fmt.Fprintf(out, "%-8s %v\n", name+":", v)
+		}
+	}
+
+	if len(s.Nodes) == 0 {
+		fmt.Fprintln(out, "\nNo nodes")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+
+	role := ""
+	for _, n := range s.Nodes {
+		if n.Role != role {
+			role = n.Role
+			fmt.Fprintf(w, "\n%s\n", role)
+			fmt.Fprintln(w, "  NAME\tZONE\tMACHINE TYPE\tINTERNAL IP\tEXTERNAL IP\tDISKS")
+		}
+
+		disks := make([]string, len(n.Disks))
+		for i, d := range n.Disks {
+			disks[i] = d.String()
+		}
+
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+			n.Name,
+			n.Zone,
+			n.MachineType,
+			n.InternalIP,
+			n.ExternalIP,
+			strings.Join(disks, ", "),
+		)
+	}
+
+	w.Flush()
+}
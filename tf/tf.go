@@ -2,16 +2,15 @@ package tf
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	golog "log"
 	"os"
-	"sort"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/asteris-llc/pony/cli"
+	"github.com/asteris-llc/pony/tf/backend"
 	"github.com/asteris-llc/pony/tf/cloud"
 	"github.com/asteris-llc/pony/tf/plugin"
 
@@ -25,6 +24,7 @@ import (
 
 const (
 	StatePath = "pony.state"
+	StateEnv  = "PONY_STATE"
 )
 
 type Tf struct {
@@ -36,6 +36,33 @@ type Tf struct {
 	state         *terraform.State
 	cloudList     *cloud.CloudList
 	cloudProvider cloud.CloudProvider
+	recordPath    string
+	overrides     *overrides
+	sources       map[string]string
+	showSources   bool
+	redactor      *redactor
+	wizard        *wizard
+	plan          *terraform.Plan
+	targets       []string
+	existing      *cli.Answers
+	config        *savedConfig
+	sensitivePass string
+	modules       map[string]string
+	stateHistory  int
+	statePath     string
+	stateOut      string
+	lockTimeout   time.Duration
+	backend       backend.Backend
+	encryptState  bool
+	stateKeyFile  string
+	stateKey      *stateKey
+	env           string
+
+	l          sync.Mutex
+	stopCh     chan struct{}
+	interrupts int
+	lockID     string
+	written    bool
 }
 
 func New() *Tf {
@@ -54,46 +81,78 @@ func New() *Tf {
 	tf.tempDir = tdir
 
 	tf.globals = newVariables()
+	tf.overrides = newOverrides()
+	tf.sources = make(map[string]string)
+	tf.redactor = newRedactor()
+	tf.modules = make(map[string]string)
+	tf.statePath = StatePath
+	tf.wizard = newWizard()
 	tf.cloudList = cloud.New(tf.cli)
 
+	// Answers are always recorded. They are saved with the state as
+	// the cluster configuration.
+	tf.cli.SetRecorder(cli.NewAnswers())
+
 	getter.Getters["builtin"] = tf
 
 	return tf
 }
 
+// SetAnswers makes every subsequent command read its values from the
+// answers file instead of prompting
+func (tf *Tf) SetAnswers(a *cli.Answers) {
+	tf.cli.SetAnswers(a)
+}
+
+// SetRecord saves every answer given during Create to path so the run
+// can be replayed with SetAnswers
+func (tf *Tf) SetRecord(path string) {
+	tf.recordPath = path
+}
+
+func (tf *Tf) SetShowSources(show bool) {
+	tf.showSources = show
+}
+
+func (tf *Tf) writeRecord() error {
+	if tf.recordPath == "" {
+		return nil
+	}
+
+	if err := tf.cli.Recorder().WriteAnswers(tf.recordPath); err != nil {
+		return err
+	}
+	log.Infof("Answers recorded to %s", tf.recordPath)
+
+	return nil
+}
+
 func (tf *Tf) String() string {
 	rval := bytes.NewBufferString("Tf structure:\n")
 
 	return rval.String()
 }
 
+// Clean removes the temporary directory and releases the state lock
 func (tf *Tf) Clean() {
 	log.Debugf("Running clean()")
+	tf.unlock()
 	os.RemoveAll(tf.tempDir)
 	os.Remove(tf.tempDir)
 }
 
 func (tf *Tf) Context(destroy bool) error {
-	golog.SetOutput(ioutil.Discard)
-
-	providers, err := plugin.Providers()
+	opts, err := tf.contextOpts()
 	if err != nil {
 		return err
 	}
 
-	provisioners, err := plugin.Provisioners()
-	if err != nil {
-		return err
-	}
+	opts.Destroy = destroy
+	opts.Module = tf.tree
+	opts.State = tf.state
+	opts.Targets = tf.targets
 
-	ctx, err := terraform.NewContext(&terraform.ContextOpts{
-		Destroy:      destroy,
-		Hooks:        []terraform.Hook{NewUiHook(&tfcli.BasicUi{Writer: os.Stdout})},
-		Module:       tf.tree,
-		Providers:    providers,
-		Provisioners: provisioners,
-		State:        tf.state,
-	})
+	ctx, err := terraform.NewContext(opts)
 	if err != nil {
 		return err
 	}
@@ -103,15 +162,34 @@ func (tf *Tf) Context(destroy bool) error {
 	return nil
 }
 
+// contextOpts returns the options shared by every terraform context
+func (tf *Tf) contextOpts() (*terraform.ContextOpts, error) {
+	golog.SetOutput(ioutil.Discard)
+
+	providers, err := plugin.Providers()
+	if err != nil {
+		return nil, err
+	}
+
+	provisioners, err := plugin.Provisioners()
+	if err != nil {
+		return nil, err
+	}
+
+	return &terraform.ContextOpts{
+		Hooks:        []terraform.Hook{NewUiHook(&redactUi{Ui: &tfcli.BasicUi{Writer: os.Stdout}, r: tf.redactor})},
+		Providers:    providers,
+		Provisioners: provisioners,
+	}, nil
+}
+
 func (tf *Tf) Plan() error {
 	p, err := tf.context.Plan()
 	if err != nil {
 		return err
 	}
 
-	if false {
-		tf.formatPlan(p)
-	}
+	tf.plan = p
 
 	return nil
 }
@@ -121,15 +199,20 @@ func (tf *Tf) Apply() error {
 	var applyErr error
 
 	doneCh := make(chan struct{})
-	ShutdownCh := make(chan struct{})
+	stopCh := tf.startApply()
+	defer tf.stopApply()
+
 	go func() {
 		defer close(doneCh)
 		s, applyErr = tf.context.Apply()
 	}()
 
+	interrupted := false
 	select {
-	case <-ShutdownCh:
+	case <-stopCh:
+		interrupted = true
 		go tf.context.Stop()
+		<-doneCh
 	case <-doneCh:
 	}
 
@@ -139,73 +222,9 @@ func (tf *Tf) Apply() error {
 		return err
 	}
 
-	return applyErr
-}
-
-func (tf *Tf) writeState() error {
-	f, err := os.Create(StatePath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	data, err := json.MarshalIndent(tf.state, "", "  ")
-	if err != nil {
-		return err
+	if applyErr == nil && interrupted {
+		return fmt.Errorf("Interrupted. Partial state written to %s", tf.stateOutBackend())
 	}
-	data = append(data, '\n')
 
-	if _, err := io.Copy(f, bytes.NewReader(data)); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (tf *Tf) formatPlan(p *terraform.Plan) {
-	created := []string{}
-	destroyed := []string{}
-	updated := []string{}
-
-	for _, m := range p.Diff.Modules {
-		for r, _ := range m.Resources {
-			name := extractResource(r)
-			switch m.ChangeType() {
-			case terraform.DiffCreate:
-				created = append(created, name)
-			case terraform.DiffDestroy:
-				destroyed = append(destroyed, name)
-			case terraform.DiffUpdate:
-				updated = append(updated, name)
-			}
-		}
-	}
-
-	fmt.Println("Resources Created:")
-	outputResources(created)
-
-	fmt.Println("\nResources Destroyed:")
-	outputResources(destroyed)
-
-	fmt.Println("\nResources Updated:")
-	outputResources(updated)
-
-	fmt.Printf("\n%+v\n", p)
-}
-
-func outputResources(s []string) {
-	if len(s) == 0 {
-		fmt.Println("  <none>")
-	} else {
-		sort.Strings(s)
-		for _, r := range s {
-			fmt.Printf("  %s\n", r)
-		}
-	}
-}
-
-func extractResource(r string) string {
-	fields := strings.Split(r, ".")
-
-	return fields[len(fields)-1]
+	return applyErr
 }
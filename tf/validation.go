@@ -0,0 +1,179 @@
+package tf
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/asteris-llc/pony/cli"
+)
+
+// Validation rules are declared per module in a meta_validation map of
+// variable name to rule:
+//
+//	variable "meta_validation" {
+//		type = "map"
+//		default = {
+//			count = "range:1-20"
+//			short_name = "gce-name"
+//			network_ipv4 = "cidr"
+//			machine_type = "regex:^[a-z][-a-z0-9]*$"
+//			volume_type = "oneof:pd-standard,pd-ssd"
+//		}
+//	}
+const (
+	MetaValidation = "meta_validation"
+)
+
+var gceNameRegexp = regexp.MustCompile("^[a-z]([-a-z0-9]*[a-z0-9])?$")
+
+// readValidation attaches the module's validation rules to its
+// variables and removes the meta variable
+func (vs *variables) readValidation() error {
+	rules, err := vs.getStringMap(MetaValidation)
+	if err != nil {
+		return err
+	}
+
+	for vname, spec := range rules {
+		v := vs.get(vname)
+		if v == nil {
+			return fmt.Errorf("Validation rule for '%s' but variable not in module", vname)
+		}
+
+		check, err := parseRule(spec)
+		if err != nil {
+			return fmt.Errorf("Invalid validation rule for '%s': %s", vname, err)
+		}
+		v.check = check
+
+		kind, _ := splitRule(spec)
+		v.numeric = kind == "range"
+	}
+
+	vs.delete(MetaValidation)
+
+	return nil
+}
+
+// validate checks a value that did not come from a prompt. Lists are
+// checked item by item.
+func (v *variable) validate(value interface{}) error {
+	if v.check == nil {
+		return nil
+	}
+
+	var items []string
+	switch t := value.(type) {
+	case []interface{}:
+		for _, e := range t {
+			items = append(items, fmt.Sprint(e))
+		}
+	case nil, map[string]interface{}, map[interface{}]interface{}:
+		return nil
+	default:
+		// Numbers from an answers or var file are checked as text
+		items = []string{fmt.Sprint(t)}
+	}
+
+	for _, item := range items {
+		if err := v.check(item); err != nil {
+			return fmt.Errorf("Invalid value for %s: %s", v.key(), err)
+		}
+	}
+
+	return nil
+}
+
+// splitRule splits a rule into its kind and argument
+func splitRule(spec string) (string, string) {
+	if idx := strings.Index(spec, ":"); idx >= 0 {
+		return spec[:idx], spec[idx+1:]
+	}
+
+	return spec, ""
+}
+
+func parseRule(spec string) (cli.Check, error) {
+	kind, arg := splitRule(spec)
+
+	switch kind {
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string) error {
+			if !re.MatchString(s) {
+				return fmt.Errorf("'%s' must match %s", s, arg)
+			}
+			return nil
+		}, nil
+	case "range":
+		return parseRange(arg)
+	case "cidr":
+		return func(s string) error {
+			if _, _, err := net.ParseCIDR(s); err != nil {
+				return fmt.Errorf("'%s' is not a CIDR block such as 10.0.0.0/16", s)
+			}
+			return nil
+		}, nil
+	case "gce-name":
+		return func(s string) error {
+			if len(s) > 63 || !gceNameRegexp.MatchString(s) {
+				return fmt.Errorf("'%s' must start with a lowercase letter, contain only lowercase letters, digits and dashes, not end with a dash and be at most 63 characters", s)
+			}
+			return nil
+		}, nil
+	case "oneof":
+		choices := strings.Split(arg, ",")
+		return func(s string) error {
+			for _, c := range choices {
+				if s == c {
+					return nil
+				}
+			}
+			return fmt.Errorf("'%s' must be one of: %s", s, strings.Join(choices, ", "))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown rule '%s'", kind)
+}
+
+// parseRange parses "min-max". Either bound may be left out.
+func parseRange(arg string) (cli.Check, error) {
+	bounds := strings.SplitN(arg, "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("Range must be min-max")
+	}
+
+	var min, max *int
+	for i, b := range bounds {
+		if b == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(b)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid range bound '%s'", b)
+		}
+		if i == 0 {
+			min = &n
+		} else {
+			max = &n
+		}
+	}
+
+	return func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a whole number", s)
+		}
+		if (min != nil && n < *min) || (max != nil && n > *max) {
+			return fmt.Errorf("%d must be in the range %s", n, arg)
+		}
+		return nil
+	}, nil
+}
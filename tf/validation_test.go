@@ -0,0 +1,117 @@
+package tf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"unknown",
+		"unknown:arg",
+		"regex:[a-z",
+		"range",
+		"range:",
+		"range:1",
+		"range:a-5",
+		"range:1-b",
+		"range:1.5-3",
+	} {
+		_, err := parseRule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec   string
+		accept []string
+		reject []string
+	}{
+		{"regex:^[a-z]+$", []string{"abc"}, []string{"", "ABC", "a1"}},
+		{"regex:a:b", []string{"a:b", "xa:bx"}, []string{"ab"}},
+		{"range:1-20", []string{"1", "10", "20"}, []string{"0", "21", "-1", "x", "1.5", ""}},
+		{"range:1-", []string{"1", "1000"}, []string{"0", "-5"}},
+		{"range:-5", []string{"0", "5"}, []string{"6"}},
+		{"cidr", []string{"10.0.0.0/16", "fd00::/8"}, []string{"10.0.0.0", "10.0.0.0/33", "net"}},
+		{"gce-name", []string{"a", "mantl", "mantl-1"}, []string{"", "1mantl", "Mantl", "mantl-", "mantl_1", strings.Repeat("a", 64)}},
+		{"oneof:pd-standard,pd-ssd", []string{"pd-standard", "pd-ssd"}, []string{"", "pd", "pd-standard,pd-ssd"}},
+	}
+
+	for _, test := range tests {
+		check, err := parseRule(test.spec)
+		if !assert.NoError(t, err, test.spec) {
+			continue
+		}
+
+		for _, s := range test.accept {
+			assert.NoError(t, check(s), "%s accepts '%s'", test.spec, s)
+		}
+		for _, s := range test.reject {
+			assert.Error(t, check(s), "%s rejects '%s'", test.spec, s)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	check, err := parseRule("range:1-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &variable{name: "count", module: "worker-nodes", check: check}
+
+	tests := []struct {
+		value interface{}
+		err   bool
+	}{
+		{"2", false},
+		{"4", true},
+		{2, false},
+		{4, true},
+		{[]interface{}{1, "3"}, false},
+		{[]interface{}{1, "5"}, true},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		err := v.validate(test.value)
+		if test.err {
+			assert.Error(t, err, "%v", test.value)
+		} else {
+			assert.NoError(t, err, "%v", test.value)
+		}
+	}
+
+	assert.NoError(t, (&variable{name: "x"}).validate("anything"))
+}
+
+func TestIsNumeric(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     interface{}
+		numeric bool
+		result  bool
+	}{
+		{"count", nil, false, true},
+		{"count", "", false, true},
+		{"worker_count", "three", false, true},
+		{"nodes", "3", false, true},
+		{"nodes", nil, true, true},
+		{"nodes", "", false, false},
+		{"short_name", "mantl", false, false},
+	}
+
+	for _, test := range tests {
+		v := &variable{
+			name:    test.name,
+			v:       &config.Variable{Name: test.name, DeclaredType: "string", Default: test.def},
+			numeric: test.numeric,
+		}
+		assert.Equal(t, test.result, v.isNumeric(), "%s = %v", test.name, test.def)
+	}
+}
@@ -3,8 +3,12 @@ package tf
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 	//	"regexp"
 
+	"github.com/asteris-llc/pony/cli"
+
 	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/config/module"
 	log "github.com/sirupsen/logrus"
@@ -25,8 +29,21 @@ type metaHandler func(*Tf, *variables) error
 type variables map[string]*variable
 
 type variable struct {
-	name string
-	v    *config.Variable
+	name   string
+	module string
+	v      *config.Variable
+
+	// Set by an override. Fixed variables are never asked for.
+	fixed bool
+
+	// Rule from the module's meta_validation map. A range rule makes
+	// the variable numeric.
+	check   cli.Check
+	numeric bool
+
+	// Listed in meta_sensitive_variables. Read without echo and
+	// redacted from output.
+	sensitive bool
 }
 
 func newVariables() *variables {
@@ -57,25 +74,34 @@ func (vs *variables) delete(name string) {
 }
 
 func (tf *Tf) ReadVariables(mh []metaHandler) error {
-	vs := newVariables()
-	vs.readVars(tf.tree)
-
 	// Process the root configuration
 	//
-	if err := tf.processModule(tf.tree, vs, mh, "Global Configuration"); err != nil {
-		return err
-	}
+	root := section{
+		name: modulePath(tf.tree),
+		run: func() error {
+			vs := newVariables()
+			vs.readVars(tf.tree)
+
+			if err := tf.processModule(tf.tree, vs, mh, "Global Configuration"); err != nil {
+				return err
+			}
 
-	// Read global variables from root. Globals will be propogated
-	// through all of the sub modules
-	//
-	tf.globals.readVars(tf.tree)
+			// Read global variables from root. Globals will be propogated
+			// through all of the sub modules
+			//
+			tf.globals = newVariables()
+			tf.globals.readVars(tf.tree)
 
-	if err := tf.processChildren(tf.tree, mh); err != nil {
+			return nil
+		},
+	}
+
+	children, err := tf.childSections(tf.tree, mh)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return tf.runSections(append([]section{root}, children...))
 }
 
 func (tf *Tf) processModule(tree *module.Tree, vs *variables, mh []metaHandler, header string) error {
@@ -83,6 +109,20 @@ func (tf *Tf) processModule(tree *module.Tree, vs *variables, mh []metaHandler,
 		fmt.Printf("\n%s\n\n", header)
 	}
 
+	if err := vs.readValidation(); err != nil {
+		return err
+	}
+
+	if err := tf.readSensitive(vs); err != nil {
+		return err
+	}
+
+	tf.envDefaults(vs)
+
+	if err := tf.applyOverrides(vs); err != nil {
+		return err
+	}
+
 	// Run through all of the meta variable handlers
 	for _, m := range mh {
 		if err := m(tf, vs); err != nil {
@@ -93,13 +133,18 @@ func (tf *Tf) processModule(tree *module.Tree, vs *variables, mh []metaHandler,
 	return nil
 }
 
-func (tf *Tf) processChildren(root *module.Tree, mh []metaHandler) error {
+// childSections returns a wizard section for each module called from
+// root
+func (tf *Tf) childSections(root *module.Tree, mh []metaHandler) ([]section, error) {
+	rval := []section{}
+
 	children := root.Children()
 	for _, m := range root.Config().Modules {
 		if _, ok := children[m.Name]; !ok {
-			return fmt.Errorf("Module %s not found in children", m.Name)
+			return nil, fmt.Errorf("Module %s not found in children", m.Name)
 		}
 
+		m := m
 		child := children[m.Name]
 
 		desc := ""
@@ -110,32 +155,41 @@ func (tf *Tf) processChildren(root *module.Tree, mh []metaHandler) error {
 			}
 		}
 
-		vs := newVariables()
-		vs.readVars(child)
+		rval = append(rval, section{
+			name: modulePath(child),
+			run: func() error {
+				return tf.processChild(m, child, mh, desc)
+			},
+		})
+	}
 
-		for k, v := range m.RawConfig.Raw {
-			switch v.(type) {
-			case string:
-			}
+	return rval, nil
+}
 
-			if t := vs.get(k); t != nil {
-				t.setValue(v)
+func (tf *Tf) processChild(m *config.Module, child *module.Tree, mh []metaHandler, desc string) error {
+	vs := newVariables()
+	vs.readVars(child)
 
-			}
+	for k, v := range m.RawConfig.Raw {
+		if t := vs.get(k); t != nil {
+			t.setValue(v)
+			tf.setSource(t, SourceModule)
 		}
-		tf.processModule(child, vs, mh, desc)
-
-		// The RawConfig variables override the module variable's Default
-		// value. We overwrite the Raw variables with whatever value the
-		// the user has set. We could also delete the key from the RawConfig
-		// if the two values are different.
-		//
-		vs = newVariables()
-		vs.readVars(child)
-		for k, _ := range m.RawConfig.Raw {
-			if t := vs.get(k); t != nil {
-				m.RawConfig.Raw[k] = t.v.Default
-			}
+	}
+	if err := tf.processModule(child, vs, mh, desc); err != nil {
+		return err
+	}
+
+	// The RawConfig variables override the module variable's Default
+	// value. We overwrite the Raw variables with whatever value the
+	// the user has set. We could also delete the key from the RawConfig
+	// if the two values are different.
+	//
+	vs = newVariables()
+	vs.readVars(child)
+	for k, _ := range m.RawConfig.Raw {
+		if t := vs.get(k); t != nil {
+			m.RawConfig.Raw[k] = t.v.Default
 		}
 	}
 
@@ -170,47 +224,288 @@ func (v *variable) setValue(value interface{}) {
 	v.v.Default = value
 }
 
+// convert turns a value read from a flag, tfvars file or answers file
+// into the form terraform expects for the variable type. Strings are
+// accepted for every type: lists are comma separated and maps are
+// comma separated key=value pairs.
+func (v *variable) convert(value interface{}) (interface{}, error) {
+	switch v.v.Type() {
+	case config.VariableTypeList:
+		switch t := value.(type) {
+		case string:
+			return stringsToList(strings.Split(t, ",")), nil
+		case []interface{}:
+			l := make([]interface{}, len(t))
+			for i, e := range t {
+				l[i] = fmt.Sprint(e)
+			}
+			return l, nil
+		}
+	case config.VariableTypeMap:
+		switch t := value.(type) {
+		case string:
+			m := make(map[string]interface{})
+			for _, pair := range strings.Split(t, ",") {
+				k, val, ok := cli.SplitPair(pair)
+				if !ok {
+					return nil, fmt.Errorf("Invalid map entry '%s'. Expected key=value", pair)
+				}
+				m[k] = val
+			}
+			return m, nil
+		case map[string]interface{}:
+			return t, nil
+		case []map[string]interface{}:
+			// HCL decodes maps as a list of maps
+			m := make(map[string]interface{})
+			for _, e := range t {
+				for k, val := range e {
+					m[k] = val
+				}
+			}
+			return m, nil
+		}
+	default:
+		switch t := value.(type) {
+		case string:
+			return t, nil
+		case []interface{}:
+			s := make([]string, len(t))
+			for i, e := range t {
+				s[i] = fmt.Sprint(e)
+			}
+			return strings.Join(s, ","), nil
+		case bool, int, int64, float64:
+			return fmt.Sprint(t), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid value type '%T' for %s variable", value, v.getType())
+}
+
+func (v *variable) checks() []cli.Check {
+	if v.check == nil {
+		return nil
+	}
+
+	return []cli.Check{v.check}
+}
+
+func (v *variable) key() string {
+	return v.module + "." + v.name
+}
+
+// hasValue reports whether the variable has a usable default
+func (v *variable) hasValue() bool {
+	switch t := v.v.Default.(type) {
+	case nil:
+		return false
+	case string:
+		return t != ""
+	case []interface{}:
+		return len(t) > 0
+	case map[string]interface{}:
+		return len(t) > 0
+	}
+
+	return true
+}
+
+// isNumeric reports whether a string variable holds a whole number,
+// such as the node counts in the builtin modules. Counts and variables
+// with a range rule are numeric whatever their default.
+func (v *variable) isNumeric() bool {
+	if !v.isType(config.VariableTypeString) {
+		return false
+	}
+
+	if v.numeric || v.name == "count" || strings.HasSuffix(v.name, "_count") {
+		return true
+	}
+
+	_, err := strconv.Atoi(v.getDefault())
+	return err == nil
+}
+
+func (v *variable) getList() []string {
+	l, ok := v.v.Default.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	rval := make([]string, len(l))
+	for i, e := range l {
+		rval[i] = fmt.Sprint(e)
+	}
+
+	return rval
+}
+
+func (v *variable) getMap() map[string]string {
+	m, ok := v.v.Default.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	rval := make(map[string]string, len(m))
+	for k, e := range m {
+		rval[k] = fmt.Sprint(e)
+	}
+
+	return rval
+}
+
 func askForValue(tf *Tf, vs *variables, name string) error {
 	v := vs.get(name)
 	if v == nil {
 		return fmt.Errorf("Variable not in config: '%s'", name)
 	}
 
-	if !v.isType(config.VariableTypeString) {
-		return fmt.Errorf("Only %s variable types supported", config.VariableTypeString.Printable())
+	if v.fixed {
+		vs.delete(name)
+		return nil
 	}
 
-	if v.v.Description != "" {
-		fmt.Printf("\n%s\n", v.v.Description)
+	if value, ok := tf.answer(v); ok {
+		cv, err := v.convert(value)
+		if err != nil {
+			return fmt.Errorf("%s (from %s): %s", v.key(), SourceAnswers, err)
+		}
+
+		if err := v.validate(cv); err != nil {
+			return err
+		}
+
+		tf.resolve(v, cv, SourceAnswers)
+		vs.delete(name)
+		return nil
 	}
 
-	def := v.getDefault()
-	prompt := v.buildPrompt(def)
+	if err := tf.useExisting(v); err != nil {
+		return err
+	}
 
-	rsp, err := tf.cli.AskRequiredWithDefault(prompt, def)
+	// Never prompt in non-interactive mode. Record the missing answer
+	// so every missing key is reported at once.
+	if !tf.cli.Interactive() {
+		if !v.hasValue() {
+			tf.cli.MissingAnswer(v.module, name)
+		}
+		vs.delete(name)
+		return nil
+	}
+
+	// The default is the previous answer when stepping back through
+	// the wizard
+	rsp, err := tf.ask(v.key(), func() (interface{}, error) {
+		if prev, ok := tf.wizard.previous(v.key()); ok {
+			v.setValue(prev)
+		}
+		return tf.promptFor(v)
+	})
 	if err != nil {
 		return err
 	}
 
-	v.setValue(rsp)
+	tf.resolve(v, rsp, SourcePrompt)
 
 	vs.delete(name)
 
 	return nil
 }
 
+// promptFor asks for a variable's value with the prompt for its type
+func (tf *Tf) promptFor(v *variable) (interface{}, error) {
+	if v.v.Description != "" {
+		fmt.Printf("\n%s\n", v.v.Description)
+	}
+
+	switch v.v.Type() {
+	case config.VariableTypeList:
+		l, err := tf.cli.AskList(v.name, v.getList(), v.checks()...)
+		if err != nil {
+			return nil, err
+		}
+		return stringsToList(l), nil
+	case config.VariableTypeMap:
+		m, err := tf.cli.AskMap(v.name, v.getMap())
+		if err != nil {
+			return nil, err
+		}
+		return stringsToMap(m), nil
+	}
+
+	def := v.getDefault()
+	prompt := v.buildPrompt(def)
+
+	switch {
+	case v.sensitive:
+		return tf.cli.AskSecret(prompt, def, v.checks()...)
+	case v.isNumeric():
+		return tf.cli.AskNumber(prompt, def, v.checks()...)
+	}
+
+	return tf.cli.AskRequiredWithDefault(prompt, def, v.checks()...)
+}
+
+// answer looks up a variable in the answers file. Root module
+// variables fall back to the provider section so that keys such as
+// project and region only need to be given once.
+func (tf *Tf) answer(v *variable) (interface{}, bool) {
+	if value, ok := tf.cli.AnswerValue(v.module, v.name); ok {
+		return value, true
+	}
+
+	if v.module == module.RootName {
+		return tf.cli.AnswerValue(cli.ProviderSection, v.name)
+	}
+
+	return nil, false
+}
+
+func stringsToList(s []string) []interface{} {
+	rval := make([]interface{}, len(s))
+	for i, e := range s {
+		rval[i] = strings.TrimSpace(e)
+	}
+
+	return rval
+}
+
+func stringsToMap(m map[string]string) map[string]interface{} {
+	rval := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		rval[k] = e
+	}
+
+	return rval
+}
+
 func (vs *variables) readVars(t *module.Tree) {
+	path := modulePath(t)
+
 	for _, v := range t.Config().Variables {
 		if vs.exists(v.Name) {
 			log.Warnf("Variable '%s' defined multiple times in module", v.Name)
 		}
 		vs.set(v.Name, &variable{
-			name: v.Name,
-			v:    v,
+			name:   v.Name,
+			module: path,
+			v:      v,
 		})
 	}
 }
 
+// modulePath returns the dotted path of a module in the tree. The root
+// module is named "root".
+func modulePath(t *module.Tree) string {
+	if p := t.Path(); len(p) > 0 {
+		return strings.Join(p, ".")
+	}
+
+	return t.Name()
+}
+
 func (vs *variables) getStringList(key string) ([]string, error) {
 	listVar := vs.get(key)
 	if listVar == nil {
@@ -237,10 +532,41 @@ func (vs *variables) getStringList(key string) ([]string, error) {
 	return rval, nil
 }
 
+func (vs *variables) getStringMap(key string) (map[string]string, error) {
+	mapVar := vs.get(key)
+	if mapVar == nil {
+		return nil, nil
+	}
+
+	if !mapVar.isType(config.VariableTypeMap) {
+		return nil, fmt.Errorf("Invlid type for '%s'. '%s' != 'map'",
+			key,
+			mapVar.getType(),
+		)
+	}
+
+	m, err := mapVar.convert(mapVar.v.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	rval := make(map[string]string)
+	for k, v := range m.(map[string]interface{}) {
+		switch v.(type) {
+		case string:
+			rval[k] = v.(string)
+		default:
+			return nil, fmt.Errorf("Invalid type for string map: '%T'", v)
+		}
+	}
+
+	return rval, nil
+}
+
 func (tf *Tf) dumpVariables(t *module.Tree) {
-	fmt.Println(t.Name())
+	log.Debugf("module: %s", t.Name())
 	for _, v := range t.Config().Variables {
-		fmt.Printf("variable: %s, value: %s\n", v.Name, v.Default)
+		log.Debugf("variable: %s, value: %s", v.Name, tf.redactor.RedactValue(v.Default))
 	}
 
 	for _, c := range t.Children() {
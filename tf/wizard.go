@@ -0,0 +1,132 @@
+package tf
+
+import (
+	"fmt"
+
+	"github.com/asteris-llc/pony/cli"
+)
+
+// A section is the group of questions for one module. Entering "<" at
+// a prompt steps back to the previous question, which may be in the
+// previous section.
+type section struct {
+	name string
+	run  func() error
+}
+
+// wizard remembers every answer so that stepping back can replay the
+// questions leading up to the one being changed. Replayed questions are
+// not shown. Questions after it are asked again with the previous
+// answer as the default.
+type wizard struct {
+	answers  map[string]interface{}
+	asked    map[string][]string
+	section  string
+	target   string
+	replayed bool
+}
+
+func newWizard() *wizard {
+	return &wizard{
+		answers: make(map[string]interface{}),
+		asked:   make(map[string][]string),
+	}
+}
+
+func (w *wizard) start(name string) {
+	w.section = name
+	w.asked[name] = nil
+}
+
+// back sets the last question asked in a section as the replay target.
+// It returns false if nothing was asked.
+func (w *wizard) back(name string) bool {
+	keys := w.asked[name]
+	if len(keys) == 0 {
+		return false
+	}
+
+	w.target = keys[len(keys)-1]
+	return true
+}
+
+func (w *wizard) previous(key string) (interface{}, bool) {
+	a, ok := w.answers[key]
+	return a, ok
+}
+
+func (tf *Tf) runSections(sections []section) error {
+	for i := 0; i < len(sections); {
+		s := sections[i]
+
+		tf.wizard.start(s.name)
+		err := s.run()
+
+		switch {
+		case cli.IsBack(err):
+			if tf.wizard.back(s.name) {
+				continue
+			}
+
+			// Nothing asked in this section yet. Go to the last
+			// question of the previous one.
+			if i > 0 {
+				i--
+				tf.wizard.back(sections[i].name)
+			}
+			continue
+		case err != nil:
+			return err
+		}
+
+		// The target was not asked again; never carry it into the
+		// next section
+		tf.wizard.target = ""
+		i++
+	}
+
+	return nil
+}
+
+// ask runs a wizard question. While stepping back, questions before
+// the target are answered from the previous run.
+func (tf *Tf) ask(key string, question func() (interface{}, error)) (interface{}, error) {
+	w := tf.wizard
+
+	if w.target != "" && key != w.target {
+		if a, ok := w.answers[key]; ok {
+			w.asked[w.section] = append(w.asked[w.section], key)
+			return a, nil
+		}
+	}
+	w.target = ""
+
+	rsp, err := question()
+	if err != nil {
+		return nil, err
+	}
+
+	w.answers[key] = rsp
+	w.asked[w.section] = append(w.asked[w.section], key)
+
+	return rsp, nil
+}
+
+// askOptional asks whether to configure a module's optional variables
+func (tf *Tf) askOptional() (bool, error) {
+	key := fmt.Sprintf("%s:optional", tf.wizard.section)
+
+	def := "n"
+	if prev, ok := tf.wizard.previous(key); ok && prev.(bool) {
+		def = "y"
+	}
+
+	rsp, err := tf.ask(key, func() (interface{}, error) {
+		return tf.cli.Confirm(fmt.Sprintf("Configure optional parameters? (y/N) [%s to go back]", cli.BackInput), def)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rsp.(bool), nil
+}